@@ -0,0 +1,188 @@
+package ginx
+
+import (
+	"bytes"
+	"context"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// APIClient calls the routes of APIHandler, unwrapping their APIResponse envelopes.
+type APIClient struct {
+	BaseURL string
+	// HTTPClient sends the requests, http.DefaultClient when nil.
+	HTTPClient *http.Client
+	// Header is added to every request, e.g. Authorization.
+	Header http.Header
+}
+
+func NewAPIClient(baseURL string) *APIClient {
+	return &APIClient{BaseURL: baseURL}
+}
+
+// Call sends req to path and returns the data of the response envelope. Like ShouldBind on the
+// server, GET requests carry req in the query string, encoded by its form tags, the others as JSON.
+// Failures are returned as *Error.
+func Call[TResp any](ctx context.Context, c *APIClient, method, path string, req any) (TResp, error) {
+	var data TResp
+	_, err := c.do(ctx, method, path, req, &data)
+	return data, err
+}
+
+// CallList is Call for routes answering a list, also returning the total from the envelope meta.
+func CallList[TResp any](ctx context.Context, c *APIClient, method, path string, req any) ([]TResp, int64, error) {
+	var data []TResp
+	meta, err := c.do(ctx, method, path, req, &data)
+	if err != nil || meta == nil {
+		return data, 0, err
+	}
+	return data, meta.Total, nil
+}
+
+func (c *APIClient) do(ctx context.Context, method, path string, req any, data any) (*ListMeta, error) {
+	target := strings.TrimRight(c.BaseURL, "/") + path
+	var body io.Reader
+	if req != nil {
+		if method == http.MethodGet {
+			query, err := EncodeForm(req)
+			if err != nil {
+				return nil, err
+			}
+			if len(query) > 0 {
+				target += "?" + query.Encode()
+			}
+		} else {
+			b, err := json.Marshal(req)
+			if err != nil {
+				return nil, err
+			}
+			body = bytes.NewReader(b)
+		}
+	}
+	r, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, vs := range c.Header {
+		r.Header[k] = vs
+	}
+	r.Header.Set("Accept", "application/json")
+	if body != nil {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(r)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= http.StatusBadRequest {
+		return nil, ResponseError(res)
+	}
+	if res.StatusCode == http.StatusNoContent {
+		return nil, nil
+	}
+	envelope := APIResponse{Data: data}
+	if err := json.NewDecoder(res.Body).Decode(&envelope); err != nil {
+		return nil, fmt.Errorf("decode %s %s: %w", method, path, err)
+	}
+	if !envelope.Success {
+		return nil, &Error{Status: res.StatusCode, Code: envelope.Code, Detail: envelope.Error, Fields: envelope.Fields}
+	}
+	return envelope.Meta, nil
+}
+
+// ResponseError reads the error answered by res, from a problem+json, APIResponse or APIError body.
+func ResponseError(res *http.Response) *Error {
+	e := &Error{Status: res.StatusCode}
+	body, _ := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	var payload struct {
+		Title  string       `json:"title"`
+		Detail string       `json:"detail"`
+		Error  string       `json:"error"`
+		Code   string       `json:"code"`
+		Fields []FieldError `json:"fields"`
+	}
+	if json.Unmarshal(body, &payload) != nil {
+		e.Detail = strings.TrimSpace(string(body))
+		return e
+	}
+	e.Code, e.Fields = payload.Code, payload.Fields
+	for _, detail := range []string{payload.Detail, payload.Error, payload.Title} {
+		if detail != "" {
+			e.Detail = detail
+			break
+		}
+	}
+	return e
+}
+
+// EncodeForm encodes the struct v as the query string form binding reads, by form tags
+// or field names. Nil pointers and zero fields tagged omitempty are left out.
+func EncodeForm(v any) (url.Values, error) {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("encode form: %T is not a struct", v)
+	}
+	values := make(url.Values)
+	encodeForm(rv, values)
+	return values, nil
+}
+
+func encodeForm(rv reflect.Value, values url.Values) {
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, opts, _ := strings.Cut(f.Tag.Get("form"), ",")
+		if name == "-" {
+			continue
+		}
+		fv := rv.Field(i)
+		if f.Anonymous && name == "" {
+			if fv = reflect.Indirect(fv); fv.Kind() == reflect.Struct {
+				encodeForm(fv, values)
+			}
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if fv.Kind() == reflect.Pointer && fv.IsNil() || strings.Contains(opts, "omitempty") && fv.IsZero() {
+			continue
+		}
+		fv = reflect.Indirect(fv)
+		if (fv.Kind() == reflect.Slice || fv.Kind() == reflect.Array) && fv.Type().Elem().Kind() != reflect.Uint8 {
+			for j := 0; j < fv.Len(); j++ {
+				values.Add(name, formValue(fv.Index(j)))
+			}
+			continue
+		}
+		values.Add(name, formValue(fv))
+	}
+}
+
+func formValue(v reflect.Value) string {
+	switch x := v.Interface().(type) {
+	case time.Time:
+		return x.Format(time.RFC3339)
+	case encoding.TextMarshaler:
+		b, err := x.MarshalText()
+		if err == nil {
+			return string(b)
+		}
+	}
+	return fmt.Sprint(v.Interface())
+}
@@ -0,0 +1,74 @@
+package ginx
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/assert/v2"
+)
+
+type testSearchReq struct {
+	Q     string   `form:"q" binding:"required"`
+	Tags  []string `form:"tag"`
+	Limit int      `form:"limit,omitempty"`
+}
+
+type testGreetReq struct {
+	Name string `json:"name" binding:"required"`
+}
+
+type testGreetResp struct {
+	Greeting string `json:"greeting"`
+}
+
+func TestAPIClient(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	app := gin.New()
+	app.GET("/search", APIHandler(func(ctx context.Context, req *testSearchReq) ([]string, error) {
+		return append([]string{req.Q}, req.Tags...), nil
+	}))
+	app.POST("/greet", APIHandler(func(ctx context.Context, req *testGreetReq) (*testGreetResp, error) {
+		// handlers run on the request context rather than the recycled gin.Context
+		if _, ok := RequestFromContext(ctx); !ok {
+			return nil, errors.New("no request context")
+		}
+		if req.Name == "nobody" {
+			return nil, NewError(http.StatusConflict, "unknown_name", "who are you")
+		}
+		return &testGreetResp{Greeting: "hello " + req.Name}, nil
+	}))
+	srv := httptest.NewServer(app)
+	defer srv.Close()
+	ctx := context.Background()
+	client := NewAPIClient(srv.URL)
+
+	found, err := Call[[]string](ctx, client, http.MethodGet, "/search", &testSearchReq{Q: "go", Tags: []string{"a", "b"}})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string{"go", "a", "b"}, found)
+
+	resp, err := Call[*testGreetResp](ctx, client, http.MethodPost, "/greet", &testGreetReq{Name: "gopher"})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "hello gopher", resp.Greeting)
+
+	_, err = Call[*testGreetResp](ctx, client, http.MethodPost, "/greet", &testGreetReq{Name: "nobody"})
+	var e *Error
+	assert.Equal(t, true, errors.As(err, &e))
+	assert.Equal(t, http.StatusConflict, e.Status)
+	assert.Equal(t, "unknown_name", e.Code)
+	assert.Equal(t, "who are you", e.Error())
+
+	_, err = Call[*testGreetResp](ctx, client, http.MethodPost, "/greet", &testGreetReq{})
+	assert.Equal(t, true, errors.As(err, &e))
+	assert.Equal(t, "validation_failed", e.Code)
+	assert.Equal(t, "name", e.Fields[0].Field)
+}
+
+func TestEncodeForm(t *testing.T) {
+	values, err := EncodeForm(&testSearchReq{Q: "x", Tags: []string{"a", "b"}})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "q=x&tag=a&tag=b", values.Encode())
+}
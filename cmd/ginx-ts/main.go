@@ -0,0 +1,72 @@
+// Command ginx-ts generates a TypeScript client from the OpenAPI document of a ginx service,
+// as served by openapi.Builder.Mount:
+//
+//	ginx-ts -in http://localhost:8080/docs/openapi.json -out src/api.ts
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/ospiper/ginx/openapi"
+	"github.com/ospiper/ginx/typescript"
+)
+
+func main() {
+	in := flag.String("in", "", "OpenAPI document, a file or an http(s) URL")
+	out := flag.String("out", "", "TypeScript file to write, stdout when empty")
+	flag.Parse()
+	if *in == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	doc, err := load(*in)
+	if err != nil {
+		log.Fatal(err)
+	}
+	w := io.Writer(os.Stdout)
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := typescript.Generate(w, doc); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func load(in string) (*openapi.Document, error) {
+	var r io.Reader
+	if strings.HasPrefix(in, "http://") || strings.HasPrefix(in, "https://") {
+		res, err := http.Get(in)
+		if err != nil {
+			return nil, err
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("GET %s: %s", in, res.Status)
+		}
+		r = res.Body
+	} else {
+		f, err := os.Open(in)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	doc := new(openapi.Document)
+	if err := json.NewDecoder(r).Decode(doc); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", in, err)
+	}
+	return doc, nil
+}
@@ -0,0 +1,56 @@
+package ginx
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-contrib/requestid"
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDContextKey is the key RequestContext stores the id assigned by RequestID under.
+const RequestIDContextKey = "ginx.request_id"
+
+// ClientIPContextKey is the key RequestContext stores the client IP, as gin resolves it, under.
+const ClientIPContextKey = "ginx.client_ip"
+
+// requestContextKey is the key RequestContext stores the *http.Request under.
+type requestContextKey struct{}
+
+// valuesContext serves the values copied from a gin.Context before those of its parent.
+type valuesContext struct {
+	context.Context
+	values map[any]any
+}
+
+func (ctx valuesContext) Value(key any) any {
+	if v, ok := ctx.values[key]; ok {
+		return v
+	}
+	return ctx.Context.Value(key)
+}
+
+// RequestContext returns the context of the request c serves, carrying the values set on c so
+// far, the request id, the client IP and the request itself, see RequestFromContext. Use it rather than c for
+// database calls and other work which may outlive the handler: database/sql keeps watching the
+// context it is given in the background, and gin recycles c for another request once the
+// handler returns.
+func RequestContext(c *gin.Context) context.Context {
+	values := c.Copy().Keys
+	if values == nil {
+		values = make(map[any]any, 3)
+	}
+	values[requestContextKey{}] = c.Request
+	values[ClientIPContextKey] = c.ClientIP()
+	if id := requestid.Get(c); id != "" {
+		values[RequestIDContextKey] = id
+	}
+	return valuesContext{Context: c.Request.Context(), values: values}
+}
+
+// RequestFromContext returns the request a context made by RequestContext belongs to, e.g. to
+// read its headers.
+func RequestFromContext(ctx context.Context) (*http.Request, bool) {
+	req, ok := ctx.Value(requestContextKey{}).(*http.Request)
+	return req, ok
+}
@@ -4,22 +4,28 @@ import (
 	"errors"
 	"time"
 
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
-// ModelStruct receives an assigned id parameter and returns a NEW model instance.
-type ModelStruct[T any] interface {
-	NewWithID(id int64) T
+// ModelStructOf receives an assigned id parameter and returns a NEW model instance.
+type ModelStructOf[T any, ID comparable] interface {
+	NewWithID(id ID) T
 }
 
+// ModelStruct is ModelStructOf for models keyed by int64.
+type ModelStruct[T any] = ModelStructOf[T, int64]
+
 type Preloader interface {
 	Preloads() []string
 }
 
-type WithID interface {
-	GetID() int64
+type WithIDOf[ID comparable] interface {
+	GetID() ID
 }
 
+type WithID = WithIDOf[int64]
+
 type Model struct {
 	ID        int64          `json:"id" gorm:"primaryKey"`
 	CreatedAt time.Time      `json:"created_at"`
@@ -51,6 +57,34 @@ func (p Permanent) GetID() int64 {
 	return p.ID
 }
 
+var ErrPermanent = errors.New("cannot delete permanent model")
+
+// PermanentModel is implemented by models embedding Permanent.
+type PermanentModel interface {
+	IsPermanent()
+}
+
+func (Permanent) IsPermanent() {}
+
 func (Permanent) BeforeDelete(tx *gorm.DB) error {
-	return errors.New("cannot delete permanent model")
+	return ErrPermanent
+}
+
+// UUIDModel is Model keyed by a UUID, generated on insert when left empty.
+type UUIDModel struct {
+	ID        uuid.UUID      `json:"id" gorm:"type:uuid;primaryKey"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"deleted_at" gorm:"index"`
+}
+
+func (m UUIDModel) GetID() uuid.UUID {
+	return m.ID
+}
+
+func (m *UUIDModel) BeforeCreate(tx *gorm.DB) error {
+	if m.ID == uuid.Nil {
+		m.ID = uuid.New()
+	}
+	return nil
 }
@@ -0,0 +1,48 @@
+package dbx
+
+import (
+	"context"
+)
+
+// TenantContextKey is the key the current tenant is stored under, see ContextWithTenant.
+const TenantContextKey = "ginx.dbx.tenant"
+
+// TenantScoped marks a model as owned by a tenant. Providers filter reads, stamp inserts
+// and guard writes by the tenant carried by the context. A context without a tenant
+// is treated as a system context and is not restricted.
+type TenantScoped struct {
+	TenantID string `json:"tenant_id" gorm:"index;not null"`
+}
+
+func (t TenantScoped) GetTenantID() string {
+	return t.TenantID
+}
+
+func (t *TenantScoped) SetTenantID(id string) {
+	t.TenantID = id
+}
+
+type WithTenant interface {
+	GetTenantID() string
+	SetTenantID(id string)
+}
+
+// Uniquer lists the column sets of a model which must be unique. Providers check them
+// before writing, within the tenant for TenantScoped models.
+type Uniquer interface {
+	UniqueKeys() [][]string
+}
+
+// ContextWithTenant returns a copy of ctx scoped to tenant.
+func ContextWithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, TenantContextKey, tenant)
+}
+
+// TenantFromContext returns the tenant carried by ctx, if any.
+func TenantFromContext(ctx context.Context) (string, bool) {
+	if ctx == nil {
+		return "", false
+	}
+	tenant, ok := ctx.Value(TenantContextKey).(string)
+	return tenant, ok && tenant != ""
+}
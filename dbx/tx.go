@@ -0,0 +1,101 @@
+package dbx
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// TxContextKey is the key a running transaction is stored under. It is a plain
+// string so that gin.Context.Set can carry the transaction through a handler chain.
+const TxContextKey = "ginx.dbx.tx"
+
+// ContextWithTx returns a copy of ctx carrying tx.
+func ContextWithTx(ctx context.Context, tx *gorm.DB) context.Context {
+	return context.WithValue(ctx, TxContextKey, tx)
+}
+
+// TxFromContext returns the transaction carried by ctx, if any.
+func TxFromContext(ctx context.Context) (*gorm.DB, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	tx, ok := ctx.Value(TxContextKey).(*gorm.DB)
+	return tx, ok && tx != nil
+}
+
+// Conn returns the transaction carried by ctx, or db when there is none, bound to ctx.
+func Conn(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if tx, ok := TxFromContext(ctx); ok {
+		return tx.WithContext(ctx)
+	}
+	return db.WithContext(ctx)
+}
+
+// CommitHooksContextKey is the key the CommitHooks of a running transaction are stored under.
+const CommitHooksContextKey = "ginx.dbx.commit_hooks"
+
+// CommitHooks collects the functions registered with AfterCommit in a transaction. Code opening
+// transactions without Transaction stores them under CommitHooksContextKey next to the
+// transaction, and runs them once it commits.
+type CommitHooks struct {
+	mu  sync.Mutex
+	fns []func()
+}
+
+func (h *CommitHooks) add(fns ...func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.fns = append(h.fns, fns...)
+}
+
+// Run runs the registered functions in order.
+func (h *CommitHooks) Run() {
+	h.mu.Lock()
+	fns := h.fns
+	h.fns = nil
+	h.mu.Unlock()
+	for _, fn := range fns {
+		fn()
+	}
+}
+
+func commitHooksFromContext(ctx context.Context) (*CommitHooks, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	hooks, ok := ctx.Value(CommitHooksContextKey).(*CommitHooks)
+	return hooks, ok && hooks != nil
+}
+
+// Transaction runs fn in a transaction. Every Provider called with the context passed to fn
+// joins the transaction. Nested calls create savepoints in the outer transaction.
+func Transaction(ctx context.Context, db *gorm.DB, fn func(ctx context.Context) error, opts ...*sql.TxOptions) error {
+	hooks := &CommitHooks{}
+	err := Conn(ctx, db).Transaction(func(tx *gorm.DB) error {
+		return fn(context.WithValue(ContextWithTx(ctx, tx), CommitHooksContextKey, hooks))
+	}, opts...)
+	if err != nil {
+		return err
+	}
+	// a savepoint hands its hooks to the enclosing transaction
+	if parent, ok := commitHooksFromContext(ctx); ok {
+		parent.add(hooks.fns...)
+		return nil
+	}
+	hooks.Run()
+	return nil
+}
+
+// AfterCommit runs fn once the transaction carried by ctx commits, or right away when ctx
+// carries none. It is dropped if the transaction, or the savepoint fn was registered in, rolls
+// back. In transactions opened without CommitHooks, fn runs right away.
+func AfterCommit(ctx context.Context, fn func()) {
+	if hooks, ok := commitHooksFromContext(ctx); ok {
+		hooks.add(fn)
+		return
+	}
+	fn()
+}
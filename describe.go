@@ -0,0 +1,84 @@
+package ginx
+
+import (
+	"path"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Operation describes what a handler binds and renders, for generators such as package openapi.
+// Types left nil are not part of the operation.
+type Operation struct {
+	// Name identifies the operation, e.g. listOrders. Generators derive one from the route when empty.
+	Name    string
+	Summary string
+	Tags    []string
+	// URI is the struct bound from the path parameters.
+	URI reflect.Type
+	// Query is the struct bound from the query string.
+	Query reflect.Type
+	// Request is bound with ShouldBind: from the query string on GET, from the body otherwise.
+	Request reflect.Type
+	// Response is the type of the rendered data, or of its records when List is set.
+	Response reflect.Type
+	// Status is the success status, 200 when zero.
+	Status int
+	// ContentTypes are the media types of the success response, application/json when empty.
+	ContentTypes []string
+	// List marks responses written with ResponseWriter.List, paginated with Content-Range.
+	List bool
+	// Envelope marks responses wrapped in APIResponse as EnvelopeWriter does.
+	Envelope bool
+	// Hidden leaves the route out of generated documents.
+	Hidden bool
+}
+
+// Router is a gin.Engine or gin.RouterGroup routes are registered on with Handle.
+type Router interface {
+	gin.IRoutes
+	BasePath() string
+}
+
+// operations maps routes to their description, by method and full path, see Handle. Describing a
+// route again, e.g. the same route of another engine, replaces its description.
+var operations = struct {
+	sync.RWMutex
+	routes map[string]*Operation
+}{routes: make(map[string]*Operation)}
+
+func operationKey(method, path string) string {
+	return method + " " + path
+}
+
+// Handle registers h for method at relativePath of r like gin's Handle, recording op as the
+// description of the route.
+func Handle(r Router, method, relativePath string, h gin.HandlerFunc, op Operation) gin.IRoutes {
+	key := operationKey(method, joinPaths(r.BasePath(), relativePath))
+	operations.Lock()
+	operations.routes[key] = &op
+	operations.Unlock()
+	return r.Handle(method, relativePath, h)
+}
+
+// joinPaths joins the path of a group and a route relative to it the way gin does.
+func joinPaths(base, relative string) string {
+	if relative == "" {
+		return base
+	}
+	p := path.Join(base, relative)
+	if strings.HasSuffix(relative, "/") && !strings.HasSuffix(p, "/") {
+		return p + "/"
+	}
+	return p
+}
+
+// OperationOf returns the description recorded for the route at path, as gin.RouteInfo has it.
+func OperationOf(method, path string) (*Operation, bool) {
+	operations.RLock()
+	defer operations.RUnlock()
+	op, ok := operations.routes[operationKey(method, path)]
+	return op, ok
+}
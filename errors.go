@@ -0,0 +1,210 @@
+package ginx
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-contrib/requestid"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"gorm.io/gorm"
+)
+
+// StatusClientClosedRequest is the non-standard status reported for requests the client gave up on.
+const StatusClientClosedRequest = 499
+
+// Error is an error carrying how it is reported over HTTP.
+type Error struct {
+	// Status is the HTTP status code
+	Status int
+	// Code is a stable machine-readable identifier, e.g. not_found
+	Code   string
+	Detail string
+	Fields []FieldError
+	Err    error
+}
+
+func NewError(status int, code, detail string) *Error {
+	return &Error{Status: status, Code: code, Detail: detail}
+}
+
+// WrapError attaches status and code to err.
+func WrapError(status int, code string, err error) *Error {
+	return &Error{Status: status, Code: code, Detail: err.Error(), Err: err}
+}
+
+func (e *Error) Error() string {
+	if e.Detail != "" {
+		return e.Detail
+	}
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return http.StatusText(e.Status)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Problem is an RFC 7807 problem details document.
+type Problem struct {
+	XMLName   xml.Name     `json:"-" xml:"urn:ietf:rfc:7807 problem"`
+	Type      string       `json:"type" xml:"type"`
+	Title     string       `json:"title" xml:"title"`
+	Status    int          `json:"status" xml:"status"`
+	Detail    string       `json:"detail,omitempty" xml:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty" xml:"instance,omitempty"`
+	Code      string       `json:"code,omitempty" xml:"code,omitempty"`
+	Fields    []FieldError `json:"fields,omitempty" xml:"fields>field,omitempty"`
+	RequestID string       `json:"request_id,omitempty" xml:"request_id,omitempty"`
+}
+
+// ErrorMapper translates errors it knows into an *Error and returns nil for the others.
+type ErrorMapper func(err error) *Error
+
+var (
+	errorMappersMu sync.RWMutex
+	errorMappers   []ErrorMapper
+)
+
+// RegisterErrorMapper adds a mapper consulted by AsError before the built-in mappings.
+func RegisterErrorMapper(fn ErrorMapper) {
+	errorMappersMu.Lock()
+	defer errorMappersMu.Unlock()
+	errorMappers = append(errorMappers, fn)
+}
+
+// AsError maps err to an *Error. It recognizes *Error anywhere in the chain, validation
+// errors, registered mappers, gorm not-found, duplicate-key and foreign-key errors,
+// and context cancellation. ok is false for errors it does not know.
+func AsError(err error) (e *Error, ok bool) {
+	if err == nil {
+		return nil, false
+	}
+	var target *Error
+	if errors.As(err, &target) {
+		ret := *target
+		// keep the context added by wrapping
+		ret.Detail = err.Error()
+		return &ret, true
+	}
+	if fields := FieldErrors(err); fields != nil {
+		return &Error{Status: http.StatusBadRequest, Code: "validation_failed", Detail: ErrValidation.Error(), Fields: fields, Err: err}, true
+	}
+	errorMappersMu.RLock()
+	mappers := errorMappers
+	errorMappersMu.RUnlock()
+	for _, fn := range mappers {
+		if e := fn(err); e != nil {
+			return e, true
+		}
+	}
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return WrapError(http.StatusNotFound, "not_found", err), true
+	case errors.Is(err, gorm.ErrDuplicatedKey) || isDuplicateKey(err):
+		return WrapError(http.StatusConflict, "duplicate", err), true
+	case errors.Is(err, gorm.ErrForeignKeyViolated) || isForeignKeyViolation(err):
+		return WrapError(http.StatusConflict, "foreign_key_violation", err), true
+	case errors.Is(err, context.Canceled):
+		return WrapError(StatusClientClosedRequest, "canceled", err), true
+	case errors.Is(err, context.DeadlineExceeded):
+		return WrapError(http.StatusGatewayTimeout, "timeout", err), true
+	}
+	return nil, false
+}
+
+// MapError is AsError reporting unknown errors as internal server errors.
+func MapError(err error) *Error {
+	if e, ok := AsError(err); ok {
+		return e
+	}
+	return WrapError(http.StatusInternalServerError, "internal", err)
+}
+
+// BindError reports a failed request binding, listing the rejected fields if there are any.
+// Bindings failing with an *Error, like ErrUnsupportedMediaType, keep it.
+func BindError(err error) *Error {
+	var target *Error
+	if errors.As(err, &target) {
+		return target
+	}
+	e := WrapError(http.StatusBadRequest, "bad_request", err)
+	if fields := FieldErrors(err); fields != nil {
+		e.Code, e.Detail, e.Fields = "validation_failed", ErrValidation.Error(), fields
+	}
+	return e
+}
+
+// driver messages for databases gorm does not translate errors of, see gorm.Config.TranslateError
+var (
+	duplicateKeyMessages = []string{
+		"UNIQUE constraint failed",        // sqlite
+		"duplicate key value",             // postgres
+		"Duplicate entry",                 // mysql
+		"Violation of UNIQUE KEY",         // sqlserver
+		"Violation of PRIMARY KEY",        // sqlserver
+		"Cannot insert duplicate key row", // sqlserver
+	}
+	foreignKeyMessages = []string{
+		"FOREIGN KEY constraint failed",   // sqlite
+		"violates foreign key constraint", // postgres
+		"a foreign key constraint fails",  // mysql
+		"conflicted with the FOREIGN KEY", // sqlserver
+		"conflicted with the REFERENCE",   // sqlserver
+	}
+)
+
+func isDuplicateKey(err error) bool {
+	return containsAny(err.Error(), duplicateKeyMessages)
+}
+
+func isForeignKeyViolation(err error) bool {
+	return containsAny(err.Error(), foreignKeyMessages)
+}
+
+func containsAny(s string, subs []string) bool {
+	for _, sub := range subs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewProblem renders err of a request as problem details.
+func NewProblem(c *gin.Context, err error) *Problem {
+	e := MapError(err)
+	title := http.StatusText(e.Status)
+	if title == "" {
+		title = e.Code
+	}
+	return &Problem{
+		Type:      "about:blank",
+		Title:     title,
+		Status:    e.Status,
+		Detail:    e.Error(),
+		Instance:  c.Request.URL.Path,
+		Code:      e.Code,
+		Fields:    e.Fields,
+		RequestID: requestid.Get(c),
+	}
+}
+
+// AbortWithProblem answers the request with err as application/problem+json, or
+// application/problem+xml, YAML or MessagePack if the client asks for them.
+func AbortWithProblem(c *gin.Context, err error) {
+	p := NewProblem(c, err)
+	switch Negotiate(c) {
+	case binding.MIMEXML, binding.MIMEXML2:
+		c.Header("Content-Type", "application/problem+xml")
+	case binding.MIMEJSON, "":
+		c.Header("Content-Type", "application/problem+json")
+	}
+	AbortWithRender(c, p.Status, p)
+}
@@ -0,0 +1,40 @@
+package ginx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+	"gorm.io/gorm"
+)
+
+func TestMapError(t *testing.T) {
+	errTeapot := NewError(http.StatusTeapot, "teapot", "short and stout")
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+	}{
+		{name: "typed", err: errTeapot, status: http.StatusTeapot, code: "teapot"},
+		{name: "wrapped", err: fmt.Errorf("brewing: %w", errTeapot), status: http.StatusTeapot, code: "teapot"},
+		{name: "record not found", err: gorm.ErrRecordNotFound, status: http.StatusNotFound, code: "not_found"},
+		{name: "translated duplicate", err: gorm.ErrDuplicatedKey, status: http.StatusConflict, code: "duplicate"},
+		{name: "sqlite duplicate", err: errors.New("UNIQUE constraint failed: users.email"), status: http.StatusConflict, code: "duplicate"},
+		{name: "foreign key", err: errors.New("FOREIGN KEY constraint failed"), status: http.StatusConflict, code: "foreign_key_violation"},
+		{name: "canceled", err: context.Canceled, status: StatusClientClosedRequest, code: "canceled"},
+		{name: "deadline", err: context.DeadlineExceeded, status: http.StatusGatewayTimeout, code: "timeout"},
+		{name: "unknown", err: errors.New("boom"), status: http.StatusInternalServerError, code: "internal"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := MapError(tt.err)
+			assert.Equal(t, tt.status, e.Status)
+			assert.Equal(t, tt.code, e.Code)
+			assert.Equal(t, tt.err.Error(), e.Error())
+		})
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"github.com/gin-contrib/requestid"
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
+
+	"github.com/ospiper/ginx"
 )
 
 type ContextLogHook struct{}
@@ -18,6 +20,11 @@ func (c *ContextLogHook) Fire(entry *logrus.Entry) error {
 	}
 	ginCtx, ok := entry.Context.(*gin.Context)
 	if !ok {
+		// contexts made by ginx.RequestContext carry both
+		if ip, ok := entry.Context.Value(ginx.ClientIPContextKey).(string); ok {
+			entry.Data["client_ip"] = ip
+			entry.Data["request_id"], _ = entry.Context.Value(ginx.RequestIDContextKey).(string)
+		}
 		return nil
 	}
 	entry.Data["client_ip"] = ginCtx.ClientIP()
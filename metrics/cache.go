@@ -0,0 +1,42 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/ospiper/ginx/rest"
+)
+
+type cacheCollector struct {
+	hits   *prometheus.Desc
+	misses *prometheus.Desc
+	caches map[string]func() rest.CacheStats
+}
+
+// NewCacheCollector exports the hits and misses of caches, e.g. the Stats of
+// rest.CachingProviderOf, as counters labelled with the name of the cache:
+//
+//	prometheus.MustRegister(metrics.NewCacheCollector("app", map[string]func() rest.CacheStats{
+//		"countries": countries.Stats,
+//	}))
+func NewCacheCollector(namespace string, caches map[string]func() rest.CacheStats) prometheus.Collector {
+	return &cacheCollector{
+		hits: prometheus.NewDesc(prometheus.BuildFQName(namespace, "cache", "hits_total"),
+			"Lookups answered from the cache.", []string{"cache"}, nil),
+		misses: prometheus.NewDesc(prometheus.BuildFQName(namespace, "cache", "misses_total"),
+			"Lookups missing the cache.", []string{"cache"}, nil),
+		caches: caches,
+	}
+}
+
+func (c *cacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.hits
+	ch <- c.misses
+}
+
+func (c *cacheCollector) Collect(ch chan<- prometheus.Metric) {
+	for name, stats := range c.caches {
+		s := stats()
+		ch <- prometheus.MustNewConstMetric(c.hits, prometheus.CounterValue, float64(s.Hits), name)
+		ch <- prometheus.MustNewConstMetric(c.misses, prometheus.CounterValue, float64(s.Misses), name)
+	}
+}
@@ -0,0 +1,191 @@
+package ginx
+
+import (
+	"cmp"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/gin-gonic/gin/render"
+)
+
+// ResponseFormats are the media types responses are negotiated in with the Accept header,
+// in order of preference when it allows several. JSON is used when there is no Accept header.
+var ResponseFormats = []string{
+	binding.MIMEJSON,
+	binding.MIMEMSGPACK2,
+	binding.MIMEMSGPACK,
+	binding.MIMEYAML2,
+	binding.MIMEYAML,
+	binding.MIMEXML,
+	binding.MIMEXML2,
+}
+
+// RequestFormats are the content types request bodies are bound from, see gin binding.Default.
+var RequestFormats = append(slices.Clone(ResponseFormats), binding.MIMEPOSTForm, binding.MIMEMultipartPOSTForm)
+
+var (
+	ErrNotAcceptable        = NewError(http.StatusNotAcceptable, "not_acceptable", "none of the accepted media types is available")
+	ErrUnsupportedMediaType = NewError(http.StatusUnsupportedMediaType, "unsupported_media_type", "unsupported content type")
+)
+
+// Negotiate returns the media type to answer c in, out of ResponseFormats, or "" if the
+// Accept header allows none of them.
+func Negotiate(c *gin.Context) string {
+	return c.NegotiateFormat(ResponseFormats...)
+}
+
+// CheckAccept returns ErrNotAcceptable if the response to c cannot be negotiated. Handlers
+// call it before doing anything, so that a request is not served only to be refused.
+func CheckAccept(c *gin.Context) error {
+	if Negotiate(c) == "" {
+		return ErrNotAcceptable
+	}
+	return nil
+}
+
+// CheckContentType returns ErrUnsupportedMediaType if the body of c is in a format it
+// cannot be bound from. Requests without a body or a Content-Type are left to binding.
+func CheckContentType(c *gin.Context) error {
+	if c.Request.Method == http.MethodGet || c.Request.ContentLength == 0 {
+		return nil
+	}
+	ct := c.ContentType()
+	if ct == "" || slices.Contains(RequestFormats, ct) {
+		return nil
+	}
+	return ErrUnsupportedMediaType
+}
+
+// Render writes data in the media type negotiated for c, JSON if none is acceptable.
+// In XML, lists are a <list> of <item> elements and maps an <object> with an element per key.
+func Render(c *gin.Context, status int, data any) {
+	c.Render(status, renderer(Negotiate(c), data))
+}
+
+// AbortWithRender is Render aborting the handler chain.
+func AbortWithRender(c *gin.Context, status int, data any) {
+	c.Abort()
+	Render(c, status, data)
+}
+
+func renderer(format string, data any) render.Render {
+	switch format {
+	case binding.MIMEMSGPACK, binding.MIMEMSGPACK2:
+		return render.MsgPack{Data: data}
+	case binding.MIMEYAML, binding.MIMEYAML2:
+		return render.YAML{Data: data}
+	case binding.MIMEXML, binding.MIMEXML2:
+		return render.XML{Data: xmlData(data, "")}
+	default:
+		return render.JSON{Data: data}
+	}
+}
+
+// xmlData returns data in a form encoding/xml encodes as a single element: lists, which it
+// encodes as sibling elements, are wrapped in a <list> of <item> elements, and maps, which it
+// cannot encode, become an <object> with an element per key. Decoded JSON, e.g. the records
+// of models with hidden fields, is encoded alike. name replaces the name of the element.
+func xmlData(data any, name string) any {
+	if raw, ok := data.(json.RawMessage); ok {
+		var v any
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return data
+		}
+		data = v
+	}
+	rv := reflect.ValueOf(data)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return data
+		}
+		return xmlList{name: name, v: rv}
+	case reflect.Map:
+		return xmlObject{name: name, v: rv}
+	}
+	return data
+}
+
+// xmlList encodes a slice as a <list>, or the element it is given, of <item> elements.
+type xmlList struct {
+	name string
+	v    reflect.Value
+}
+
+func (l xmlList) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Name = xml.Name{Local: cmp.Or(l.name, "list")}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	for i := 0; i < l.v.Len(); i++ {
+		item := xml.StartElement{Name: xml.Name{Local: "item"}}
+		if err := e.EncodeElement(xmlData(l.v.Index(i).Interface(), "item"), item); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// xmlObject encodes a map as an <object>, or the element it is given, with an element per key
+// in order. Keys which are no XML names, e.g. 1 or a b, are encoded as <entry key="...">.
+type xmlObject struct {
+	name string
+	v    reflect.Value
+}
+
+func (o xmlObject) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Name = xml.Name{Local: cmp.Or(o.name, "object")}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	keys := make([]string, 0, o.v.Len())
+	values := make(map[string]reflect.Value, o.v.Len())
+	for it := o.v.MapRange(); it.Next(); {
+		k := fmt.Sprint(it.Key().Interface())
+		keys = append(keys, k)
+		values[k] = it.Value()
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		el := xml.StartElement{Name: xml.Name{Local: k}}
+		if !xmlName(k) {
+			el = xml.StartElement{Name: xml.Name{Local: "entry"}, Attr: []xml.Attr{{Name: xml.Name{Local: "key"}, Value: k}}}
+		}
+		v := xmlData(values[k].Interface(), k)
+		if rv := reflect.ValueOf(v); !rv.IsValid() || rv.Kind() == reflect.Pointer && rv.IsNil() {
+			// encoding/xml leaves nil out, keep the key
+			if err := e.EncodeToken(el); err != nil {
+				return err
+			}
+			if err := e.EncodeToken(el.End()); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := e.EncodeElement(v, el); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// xmlName reports whether s can name an element on its own: a letter or an underscore followed
+// by letters, digits, hyphens, dots and underscores. Colons are left out, they denote namespaces.
+func xmlName(s string) bool {
+	for i, r := range s {
+		switch {
+		case r == '_' || unicode.IsLetter(r):
+		case i > 0 && (r == '-' || r == '.' || unicode.IsDigit(r)):
+		default:
+			return false
+		}
+	}
+	return s != ""
+}
@@ -0,0 +1,100 @@
+package ginx
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/assert/v2"
+)
+
+func TestNegotiation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	app := gin.New()
+	calls := 0
+	app.POST("/greet", RESTHandler(func(ctx context.Context, req *testGreetReq) (*testGreetResp, error) {
+		calls++
+		return &testGreetResp{Greeting: "hello " + req.Name}, nil
+	}))
+	serve := func(contentType, accept, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/greet", strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve("application/json", "", `{"name":"json"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `{"greeting":"hello json"}`, w.Body.String())
+
+	w = serve("application/yaml", "application/yaml", "name: yaml\n")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, true, strings.HasPrefix(w.Header().Get("Content-Type"), "application/yaml"))
+	assert.Equal(t, "greeting: hello yaml\n", w.Body.String())
+
+	w = serve("application/xml", "text/html, application/xml;q=0.9", "<testGreetReq><Name>xml</Name></testGreetReq>")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "<testGreetResp><Greeting>hello xml</Greeting></testGreetResp>", w.Body.String())
+
+	w = serve("application/json", "application/msgpack", `{"name":"msgpack"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, true, strings.HasPrefix(w.Header().Get("Content-Type"), "application/msgpack"))
+
+	w = serve("application/json", "application/xml", `{}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "application/problem+xml", w.Header().Get("Content-Type"))
+	assert.Equal(t, true, strings.Contains(w.Body.String(), `<problem xmlns="urn:ietf:rfc:7807"><type>about:blank</type>`))
+
+	calls = 0
+	w = serve("application/json", "text/html", `{"name":"html"}`)
+	assert.Equal(t, http.StatusNotAcceptable, w.Code)
+	assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+	assert.Equal(t, 0, calls)
+
+	w = serve("text/plain", "", "name=plain")
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+	assert.Equal(t, true, strings.Contains(w.Body.String(), `"code":"unsupported_media_type"`))
+	assert.Equal(t, 0, calls)
+}
+
+func TestRenderXML(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	app := gin.New()
+	app.GET("/list", func(c *gin.Context) {
+		Render(c, http.StatusOK, []*testGreetResp{{Greeting: "a"}, {Greeting: "b"}})
+	})
+	app.GET("/object", func(c *gin.Context) {
+		Render(c, http.StatusOK, []map[string]json.RawMessage{
+			{"name": json.RawMessage(`"x"`), "tags": json.RawMessage(`["a",1]`), "none": json.RawMessage(`null`)},
+		})
+	})
+	app.GET("/keys", func(c *gin.Context) {
+		Render(c, http.StatusOK, map[string]any{"1": 1, "a b": "x", "x:y": true, "ok": nil})
+	})
+	serve := func(target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("Accept", "application/xml")
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve("/list")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "<list><item><Greeting>a</Greeting></item><item><Greeting>b</Greeting></item></list>", w.Body.String())
+	w = serve("/object")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "<list><item><name>x</name><none></none><tags><item>a</item><item>1</item></tags></item></list>", w.Body.String())
+	// keys which are no element names
+	w = serve("/keys")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `<object><entry key="1">1</entry><entry key="a b">x</entry><ok></ok><entry key="x:y">true</entry></object>`, w.Body.String())
+}
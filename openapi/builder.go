@@ -0,0 +1,242 @@
+package openapi
+
+import (
+	_ "embed"
+	"html/template"
+	"net/http"
+	"path"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	swaggerFiles "github.com/swaggo/files/v2"
+
+	"github.com/ospiper/ginx"
+)
+
+// Builder describes the routes of a gin engine as an OpenAPI 3.1 document. Routes registered
+// with ginx.Handle, the ginx handler families and the rest controllers carry their types, the
+// others are listed without schemas.
+type Builder struct {
+	Title       string
+	Version     string
+	Description string
+	Servers     []Server
+}
+
+// Build documents routes, typically engine.Routes().
+func (b Builder) Build(routes gin.RoutesInfo) *Document {
+	g := newSchemas()
+	doc := &Document{
+		OpenAPI: Version,
+		Info: Info{
+			Title:       b.Title,
+			Version:     b.Version,
+			Description: b.Description,
+		},
+		Servers: b.Servers,
+		Paths:   make(map[string]*PathItem),
+	}
+	for _, route := range routes {
+		op, ok := ginx.OperationOf(route.Method, route.Path)
+		if !ok {
+			op = &ginx.Operation{}
+		}
+		if op.Hidden {
+			continue
+		}
+		p, params := pathOf(route.Path)
+		item, ok := doc.Paths[p]
+		if !ok {
+			item = &PathItem{}
+			doc.Paths[p] = item
+		}
+		(*item)[strings.ToLower(route.Method)] = g.operation(route.Method, p, params, op)
+	}
+	doc.Components.Schemas = g.components
+	return doc
+}
+
+// pathOf converts the gin route path into an OpenAPI one, returning the names of its parameters.
+func pathOf(route string) (string, []string) {
+	var params []string
+	segments := strings.Split(route, "/")
+	for i, s := range segments {
+		if strings.HasPrefix(s, ":") || strings.HasPrefix(s, "*") {
+			params = append(params, s[1:])
+			segments[i] = "{" + s[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// operationID derives an id like getOrdersById for routes without an ginx.Operation name.
+func operationID(method, p string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	for _, s := range strings.FieldsFunc(p, func(r rune) bool { return r == '/' }) {
+		if strings.HasPrefix(s, "{") {
+			b.WriteString("By")
+			s = strings.Trim(s, "{}")
+		}
+		for _, part := range strings.FieldsFunc(s, func(r rune) bool { return r == '-' || r == '_' || r == '.' }) {
+			b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+		}
+	}
+	return b.String()
+}
+
+func (g *schemas) operation(method, p string, params []string, op *ginx.Operation) *Operation {
+	ret := &Operation{
+		OperationID: op.Name,
+		Summary:     op.Summary,
+		Tags:        op.Tags,
+		Responses:   make(map[string]*Response),
+	}
+	if ret.OperationID == "" {
+		ret.OperationID = operationID(method, p)
+	}
+
+	var uri []*Parameter
+	if op.URI != nil {
+		uri = g.params(op.URI, "path", "uri")
+	}
+	for _, name := range params {
+		param := &Parameter{Name: name, In: "path", Required: true, Schema: &Schema{Type: "string"}}
+		for _, u := range uri {
+			if u.Name == name {
+				param.Schema = u.Schema
+			}
+		}
+		ret.Parameters = append(ret.Parameters, param)
+	}
+	if op.Query != nil {
+		ret.Parameters = append(ret.Parameters, g.params(op.Query, "query", "form")...)
+	}
+	if op.Request != nil && !empty(op.Request) {
+		if method == http.MethodGet {
+			ret.Parameters = append(ret.Parameters, g.params(op.Request, "query", "form")...)
+		} else {
+			ret.RequestBody = &RequestBody{
+				Required: true,
+				Content:  map[string]*MediaType{"application/json": {Schema: g.of(op.Request)}},
+			}
+		}
+	}
+
+	if op.Response == nil && op.Status == 0 {
+		ret.Responses["default"] = &Response{Description: "unspecified"}
+		return ret
+	}
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	ret.Responses[strconv.Itoa(status)] = g.response(status, op)
+	if op.List {
+		ret.Responses[strconv.Itoa(http.StatusPartialContent)] = g.response(http.StatusPartialContent, op)
+	}
+	if op.Envelope {
+		ret.Responses["default"] = &Response{
+			Description: "error",
+			Content:     map[string]*MediaType{"application/json": {Schema: g.of(reflect.TypeFor[ginx.APIResponse]())}},
+		}
+	} else {
+		ret.Responses["default"] = &Response{
+			Description: "error",
+			Content:     map[string]*MediaType{"application/problem+json": {Schema: g.of(reflect.TypeFor[ginx.Problem]())}},
+		}
+	}
+	return ret
+}
+
+func (g *schemas) response(status int, op *ginx.Operation) *Response {
+	ret := &Response{Description: http.StatusText(status)}
+	if op.List {
+		ret.Headers = map[string]*Header{
+			"Content-Range": {
+				Description: "range of the records returned, e.g. items 0-24/100",
+				Schema:      &Schema{Type: "string"},
+			},
+		}
+	}
+	if op.Response == nil || status == http.StatusNoContent {
+		return ret
+	}
+	if len(op.ContentTypes) > 0 && !slices.Contains(op.ContentTypes, "application/json") {
+		ret.Content = make(map[string]*MediaType)
+		for _, ct := range op.ContentTypes {
+			ret.Content[ct] = &MediaType{Schema: &Schema{Type: "string"}}
+		}
+		return ret
+	}
+	data := g.of(op.Response)
+	if op.List {
+		data = &Schema{Type: "array", Items: data}
+	}
+	if op.Envelope {
+		data = &Schema{
+			Type: "object",
+			Properties: map[string]*Schema{
+				"success":    {Type: "boolean"},
+				"data":       data,
+				"request_id": {Type: "string"},
+			},
+			Required: []string{"success", "data"},
+		}
+		if op.List {
+			data.Properties["meta"] = g.of(reflect.TypeFor[ginx.ListMeta]())
+		}
+	}
+	ret.Content = map[string]*MediaType{"application/json": {Schema: data}}
+	return ret
+}
+
+// empty reports whether t binds nothing, like ginx.Empty.
+func empty(t reflect.Type) bool {
+	t = deref(t)
+	return t.Kind() == reflect.Struct && t.NumField() == 0
+}
+
+//go:embed docs.html
+var docsHTML string
+
+var docsPage = template.Must(template.New("docs").Parse(docsHTML))
+
+// Mount serves the document of the routes of engine at <prefix>/openapi.json and a Swagger UI page
+// rendering it at prefix. The document is built on its first request, once every route is
+// registered. The page loads the Swagger UI release embedded in the binary, served at
+// <prefix>/assets, and no third-party script.
+func (b Builder) Mount(engine *gin.Engine, prefix string) {
+	var (
+		once sync.Once
+		doc  *Document
+	)
+	group := engine.Group(prefix)
+	hidden := ginx.Operation{Hidden: true}
+	ginx.Handle(group, http.MethodGet, "/openapi.json", func(c *gin.Context) {
+		once.Do(func() {
+			doc = b.Build(engine.Routes())
+		})
+		c.JSON(http.StatusOK, doc)
+	}, hidden)
+	assets := http.FS(swaggerFiles.FS)
+	ginx.Handle(group, http.MethodGet, "/assets/*file", func(c *gin.Context) {
+		c.FileFromFS(c.Param("file"), assets)
+	}, hidden)
+	page := map[string]string{
+		"Title":   b.Title,
+		"SpecURL": path.Join(group.BasePath(), "openapi.json"),
+		"Assets":  path.Join(group.BasePath(), "assets"),
+	}
+	ginx.Handle(group, http.MethodGet, "", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+		c.Header("Content-Type", "text/html; charset=utf-8")
+		if err := docsPage.Execute(c.Writer, page); err != nil {
+			_ = c.Error(err)
+		}
+	}, hidden)
+}
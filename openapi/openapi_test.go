@@ -0,0 +1,144 @@
+package openapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/assert/v2"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/ospiper/ginx"
+	"github.com/ospiper/ginx/dbx"
+	"github.com/ospiper/ginx/rest"
+)
+
+type testBook struct {
+	dbx.Model
+	Title  string   `json:"title" binding:"required,max=100"`
+	Genre  string   `json:"genre,omitempty" binding:"omitempty,oneof=fiction poetry"`
+	Rating *float64 `json:"rating"`
+	Tags   []string `json:"tags" gorm:"serializer:json"`
+	secret string
+}
+
+func (testBook) NewWithID(id int64) testBook {
+	return testBook{Model: dbx.Model{ID: id}}
+}
+
+type searchReq struct {
+	Q     string `form:"q" binding:"required"`
+	Limit int    `form:"limit" binding:"min=1,max=50"`
+}
+
+type renameReq struct {
+	Name string `json:"name" binding:"required"`
+}
+
+type renameUri struct {
+	ID int64 `uri:"id" binding:"required"`
+}
+
+func TestBuild(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open("file::memory:"))
+	assert.Equal(t, nil, err)
+	app := gin.New()
+	(&rest.ResourceController[testBook]{
+		Name:     "books",
+		Provider: rest.NewProvider[testBook](db),
+		Group:    app.Group("/books"),
+		Import:   true,
+		Export:   true,
+	}).Register()
+	ginx.HandleREST(app, http.MethodGet, "/search", func(ctx context.Context, req *searchReq) ([]testBook, error) {
+		return nil, nil
+	})
+	ginx.HandleAPIWithUriParams(app, http.MethodPost, "/authors/:id/rename", func(ctx context.Context, req *renameReq, uri *renameUri) (*ginx.Empty, error) {
+		return nil, nil
+	})
+	app.GET("/health", func(c *gin.Context) {})
+	Builder{Title: "books", Version: "1.0.0"}.Mount(app, "/docs")
+
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/docs/openapi.json", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var doc Document
+	assert.Equal(t, nil, json.Unmarshal(w.Body.Bytes(), &doc))
+	assert.Equal(t, Version, doc.OpenAPI)
+	assert.Equal(t, 7, len(doc.Paths))
+	_, ok := doc.Paths["/docs"]
+	assert.Equal(t, false, ok)
+
+	list := (*doc.Paths["/books"])["get"]
+	assert.Equal(t, "listBooks", list.OperationID)
+	assert.Equal(t, []string{"books"}, list.Tags)
+	names := make([]string, 0, len(list.Parameters))
+	for _, p := range list.Parameters {
+		names = append(names, p.Name)
+	}
+	assert.Equal(t, []string{"filter", "sort", "range", "embed"}, names)
+	assert.Equal(t, "#/components/schemas/testBook", list.Responses["200"].Content["application/json"].Schema.Items.Ref)
+	assert.Equal(t, "string", list.Responses["206"].Headers["Content-Range"].Schema.Type)
+
+	export := (*doc.Paths["/books/export"])["get"]
+	assert.Equal(t, "exportBooks", export.OperationID)
+	assert.Equal(t, "string", export.Responses["200"].Content["text/csv; charset=utf-8"].Schema.Type)
+
+	get := (*doc.Paths["/books/{id}"])["get"]
+	assert.Equal(t, "id", get.Parameters[0].Name)
+	assert.Equal(t, "integer", get.Parameters[0].Schema.Type)
+	assert.Equal(t, "#/components/schemas/Problem", get.Responses["default"].Content["application/problem+json"].Schema.Ref)
+	del := (*doc.Paths["/books/{id}"])["delete"]
+	assert.Equal(t, 0, len(del.Responses["204"].Content))
+
+	book := doc.Components.Schemas["testBook"]
+	assert.Equal(t, []string{"title"}, book.Required)
+	assert.Equal(t, 100, *book.Properties["title"].MaxLength)
+	assert.Equal(t, []any{"fiction", "poetry"}, book.Properties["genre"].Enum)
+	assert.Equal(t, []any{"number", "null"}, book.Properties["rating"].Type)
+	assert.Equal(t, "array", book.Properties["tags"].Type)
+	assert.Equal(t, "date-time", book.Properties["created_at"].Format)
+	_, ok = book.Properties["secret"]
+	assert.Equal(t, false, ok)
+
+	search := (*doc.Paths["/search"])["get"]
+	assert.Equal(t, "getSearch", search.OperationID)
+	assert.Equal(t, true, search.Parameters[0].Required)
+	assert.Equal(t, float64(50), *search.Parameters[1].Schema.Maximum)
+
+	rename := (*doc.Paths["/authors/{id}/rename"])["post"]
+	assert.Equal(t, "postAuthorsByIdRename", rename.OperationID)
+	assert.Equal(t, "#/components/schemas/renameReq", rename.RequestBody.Content["application/json"].Schema.Ref)
+	data := rename.Responses["200"].Content["application/json"].Schema
+	assert.Equal(t, "#/components/schemas/Empty", data.Properties["data"].Ref)
+	assert.Equal(t, "#/components/schemas/APIResponse", rename.Responses["default"].Content["application/json"].Schema.Ref)
+
+	health := (*doc.Paths["/health"])["get"]
+	assert.Equal(t, "unspecified", health.Responses["default"].Description)
+
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, true, strings.Contains(w.Body.String(), `url: "/docs/openapi.json"`))
+	assert.Equal(t, true, strings.Contains(w.Body.String(), `<script src="/docs/assets/swagger-ui-bundle.js"></script>`))
+	// the page loads the assets embedded in the binary
+	for _, asset := range []string{"/docs/assets/swagger-ui-bundle.js", "/docs/assets/swagger-ui.css"} {
+		w = httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, asset, nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotEqual(t, 0, w.Body.Len())
+	}
+}
+
+func TestSchemaNames(t *testing.T) {
+	g := newSchemas()
+	s := g.of(reflect.TypeFor[rest.PagedResults[testBook]]())
+	assert.Equal(t, "#/components/schemas/PagedResults_testBook", s.Ref)
+}
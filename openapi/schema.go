@@ -0,0 +1,271 @@
+package openapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	timeType      = reflect.TypeFor[time.Time]()
+	deletedAtType = reflect.TypeFor[gorm.DeletedAt]()
+	uuidType      = reflect.TypeFor[uuid.UUID]()
+	rawType       = reflect.TypeFor[json.RawMessage]()
+	jsonMarshaler = reflect.TypeFor[json.Marshaler]()
+	textMarshaler = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+// schemas reflects Go types into schemas, collecting named structs as components.
+type schemas struct {
+	components map[string]*Schema
+	names      map[reflect.Type]string
+}
+
+func newSchemas() *schemas {
+	return &schemas{
+		components: make(map[string]*Schema),
+		names:      make(map[reflect.Type]string),
+	}
+}
+
+// of returns the schema of t, a $ref for named structs. Pointers are nullable.
+func (g *schemas) of(t reflect.Type) *Schema {
+	nullable := false
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+		nullable = true
+	}
+	s := g.schema(t)
+	if nullable && s.Ref == "" && s.Type != nil {
+		s.Type = []any{s.Type, "null"}
+	}
+	return s
+}
+
+func (g *schemas) schema(t reflect.Type) *Schema {
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case deletedAtType:
+		return &Schema{Type: []any{"string", "null"}, Format: "date-time"}
+	case uuidType:
+		return &Schema{Type: "string", Format: "uuid"}
+	case rawType:
+		return &Schema{}
+	}
+	if implements(t, jsonMarshaler) {
+		return &Schema{}
+	}
+	if implements(t, textMarshaler) {
+		return &Schema{Type: "string"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint, reflect.Uint64, reflect.Uintptr:
+		return &Schema{Type: "integer", Minimum: float(0)}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.of(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.of(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		return g.ref(t)
+	}
+	return &Schema{}
+}
+
+// ref registers the named struct t as a component and refers to it.
+func (g *schemas) ref(t reflect.Type) *Schema {
+	name, ok := g.names[t]
+	if !ok {
+		name = g.name(t)
+		g.names[t] = name
+		g.components[name] = nil // reserved while t refers to itself
+		g.components[name] = g.object(t)
+	}
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+var (
+	qualifier = regexp.MustCompile(`[\w./-]*[./]`)
+	unsafeRun = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+)
+
+// name is a component name for t unused so far, e.g. PagedResults_Order for PagedResults[pkg.Order].
+func (g *schemas) name(t reflect.Type) string {
+	name := qualifier.ReplaceAllString(t.Name(), "")
+	name = strings.Trim(unsafeRun.ReplaceAllString(name, "_"), "_")
+	unique := name
+	for i := 2; ; i++ {
+		if _, taken := g.components[unique]; !taken {
+			return unique
+		}
+		unique = name + strconv.Itoa(i)
+	}
+}
+
+func (g *schemas) object(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	g.fields(t, s)
+	return s
+}
+
+// fields adds the properties of the struct t to s, following the rules of encoding/json.
+func (g *schemas) fields(t reflect.Type, s *Schema) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && name == "" {
+			if ft := deref(f.Type); ft.Kind() == reflect.Struct {
+				g.fields(ft, s)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		prop := g.of(f.Type)
+		if constrain(prop, f) {
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = prop
+	}
+}
+
+// params returns the parameters in, named by tag, bound into the struct t.
+func (g *schemas) params(t reflect.Type, in, tag string) []*Parameter {
+	var ps []*Parameter
+	t = deref(t)
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get(tag), ",")
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && name == "" {
+			ps = append(ps, g.params(f.Type, in, tag)...)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		p := &Parameter{Name: name, In: in, Schema: g.of(f.Type)}
+		p.Required = constrain(p.Schema, f) || in == "path"
+		ps = append(ps, p)
+	}
+	return ps
+}
+
+// constrain applies the binding rules of f to s and reports whether f is required.
+func constrain(s *Schema, f reflect.StructField) bool {
+	required := false
+	kind := deref(f.Type).Kind()
+	for _, rule := range strings.Split(f.Tag.Get("binding"), ",") {
+		tag, param, _ := strings.Cut(rule, "=")
+		switch tag {
+		case "dive":
+			return required
+		case "required":
+			required = true
+		case "email":
+			s.Format = "email"
+		case "url", "uri":
+			s.Format = "uri"
+		case "uuid", "uuid4":
+			s.Format = "uuid"
+		case "oneof":
+			for _, v := range strings.Fields(param) {
+				if n, err := strconv.ParseFloat(v, 64); err == nil && kind != reflect.String {
+					s.Enum = append(s.Enum, n)
+				} else {
+					s.Enum = append(s.Enum, v)
+				}
+			}
+		case "min", "gte", "max", "lte", "len":
+			n, err := strconv.ParseFloat(param, 64)
+			if err != nil {
+				continue
+			}
+			lower, upper := tag != "max" && tag != "lte", tag != "min" && tag != "gte"
+			switch kind {
+			case reflect.String:
+				if lower {
+					s.MinLength = count(n)
+				}
+				if upper {
+					s.MaxLength = count(n)
+				}
+			case reflect.Slice, reflect.Array, reflect.Map:
+				if lower {
+					s.MinItems = count(n)
+				}
+				if upper {
+					s.MaxItems = count(n)
+				}
+			default:
+				if lower {
+					s.Minimum = float(n)
+				}
+				if upper {
+					s.Maximum = float(n)
+				}
+			}
+		}
+	}
+	return required
+}
+
+func implements(t, iface reflect.Type) bool {
+	return t.Implements(iface) || reflect.PointerTo(t).Implements(iface)
+}
+
+func deref(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+func float(n float64) *float64 {
+	return &n
+}
+
+func count(n float64) *int {
+	c := int(n)
+	return &c
+}
@@ -3,8 +3,8 @@ package ginx
 import (
 	"context"
 	"net/http"
+	"reflect"
 
-	"github.com/gin-contrib/requestid"
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 )
@@ -13,8 +13,10 @@ type Empty struct {
 }
 
 type APIError struct {
-	Error     string `json:"error"`
-	RequestID string `json:"request_id"`
+	Error     string       `json:"error"`
+	Code      string       `json:"code,omitempty"`
+	Fields    []FieldError `json:"fields,omitempty"`
+	RequestID string       `json:"request_id"`
 }
 
 func RESTHandler[TReq, TResp any](handler func(context.Context, *TReq) (TResp, error)) func(*gin.Context) {
@@ -24,39 +26,39 @@ func RESTHandler[TReq, TResp any](handler func(context.Context, *TReq) (TResp, e
 }
 
 func RESTHandlerWithUriParams[TReq, TResp, TUri any](handler func(context.Context, *TReq, *TUri) (TResp, error)) func(*gin.Context) {
+	Validator()
 	return func(c *gin.Context) {
 		log := logrus.WithContext(c)
+		w := WriterOf(c, JSONWriter{})
+		if err := CheckAccept(c); err != nil {
+			w.Error(c, err)
+			return
+		}
 		uriReq := new(TUri)
-		requestID := requestid.Get(c)
 		err := c.ShouldBindUri(uriReq)
 		if err != nil {
 			log.WithError(err).Error()
-			c.JSON(http.StatusBadRequest, APIError{
-				Error:     err.Error(),
-				RequestID: requestID,
-			})
+			w.Error(c, BindError(err))
+			return
+		}
+		if err := CheckContentType(c); err != nil {
+			w.Error(c, err)
 			return
 		}
 		req := new(TReq)
 		err = c.ShouldBind(req)
 		if err != nil {
 			log.WithError(err).Error()
-			c.JSON(http.StatusBadRequest, APIError{
-				Error:     err.Error(),
-				RequestID: requestID,
-			})
+			w.Error(c, BindError(err))
 			return
 		}
-		resp, err := handler(c, req, uriReq)
+		resp, err := handler(RequestContext(c), req, uriReq)
 		if err != nil {
 			log.WithError(err).Error()
-			c.JSON(http.StatusBadRequest, &APIError{
-				Error:     err.Error(),
-				RequestID: requestID,
-			})
+			w.Error(c, err)
 			return
 		}
-		c.JSON(http.StatusOK, resp)
+		w.Success(c, http.StatusOK, resp)
 	}
 }
 
@@ -65,10 +67,17 @@ type Normalizer interface {
 }
 
 type APIResponse struct {
-	Success   bool   `json:"success"`
-	Data      any    `json:"data"`
-	Error     string `json:"error,omitempty"`
-	RequestID string `json:"request_id,omitempty"`
+	Success   bool         `json:"success"`
+	Data      any          `json:"data"`
+	Meta      *ListMeta    `json:"meta,omitempty"`
+	Error     string       `json:"error,omitempty"`
+	Code      string       `json:"code,omitempty"`
+	Fields    []FieldError `json:"fields,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+}
+
+type ListMeta struct {
+	Total int64 `json:"total"`
 }
 
 func APIHandler[TReq, TResp any](handler func(context.Context, *TReq) (TResp, error)) func(*gin.Context) {
@@ -78,27 +87,30 @@ func APIHandler[TReq, TResp any](handler func(context.Context, *TReq) (TResp, er
 }
 
 func APIHandlerWithUriParams[TReq, TResp, TUri any](handler func(context.Context, *TReq, *TUri) (TResp, error)) func(*gin.Context) {
+	Validator()
 	return func(c *gin.Context) {
 		log := logrus.WithContext(c)
-		requestID := requestid.Get(c)
+		w := WriterOf(c, EnvelopeWriter{})
+		if err := CheckAccept(c); err != nil {
+			w.Error(c, err)
+			return
+		}
 		uriReq := new(TUri)
 		err := c.ShouldBindUri(uriReq)
 		if err != nil {
 			log.WithError(err).Error()
-			c.JSON(http.StatusBadRequest, APIResponse{
-				Error:     err.Error(),
-				RequestID: requestID,
-			})
+			w.Error(c, BindError(err))
+			return
+		}
+		if err := CheckContentType(c); err != nil {
+			w.Error(c, err)
 			return
 		}
 		req := new(TReq)
 		err = c.ShouldBind(req)
 		if err != nil {
 			log.WithError(err).Error()
-			c.JSON(http.StatusBadRequest, APIResponse{
-				Error:     err.Error(),
-				RequestID: requestID,
-			})
+			w.Error(c, BindError(err))
 			return
 		}
 		if normalizer, ok := any(req).(Normalizer); ok {
@@ -108,19 +120,42 @@ func APIHandlerWithUriParams[TReq, TResp, TUri any](handler func(context.Context
 				}).Info("normalized req")
 			}
 		}
-		resp, err := handler(c, req, uriReq)
+		resp, err := handler(RequestContext(c), req, uriReq)
 		if err != nil {
 			log.WithError(err).Error()
-			c.JSON(http.StatusOK, &APIResponse{
-				Error:     err.Error(),
-				RequestID: requestID,
-			})
+			w.Error(c, err)
 			return
 		}
-		c.JSON(http.StatusOK, &APIResponse{
-			Success:   true,
-			Data:      resp,
-			RequestID: requestID,
-		})
+		w.Success(c, http.StatusOK, resp)
 	}
 }
+
+// handlerOperation describes the typed handlers.
+func handlerOperation[TReq, TResp, TUri any](envelope bool) Operation {
+	return Operation{
+		URI:      reflect.TypeFor[TUri](),
+		Request:  reflect.TypeFor[TReq](),
+		Response: reflect.TypeFor[TResp](),
+		Envelope: envelope,
+	}
+}
+
+// HandleREST registers RESTHandler(handler) on r like Handle, described by the types of handler.
+func HandleREST[TReq, TResp any](r Router, method, relativePath string, handler func(context.Context, *TReq) (TResp, error)) gin.IRoutes {
+	return Handle(r, method, relativePath, RESTHandler(handler), handlerOperation[TReq, TResp, Empty](false))
+}
+
+// HandleRESTWithUriParams is HandleREST for RESTHandlerWithUriParams.
+func HandleRESTWithUriParams[TReq, TResp, TUri any](r Router, method, relativePath string, handler func(context.Context, *TReq, *TUri) (TResp, error)) gin.IRoutes {
+	return Handle(r, method, relativePath, RESTHandlerWithUriParams(handler), handlerOperation[TReq, TResp, TUri](false))
+}
+
+// HandleAPI is HandleREST for APIHandler.
+func HandleAPI[TReq, TResp any](r Router, method, relativePath string, handler func(context.Context, *TReq) (TResp, error)) gin.IRoutes {
+	return Handle(r, method, relativePath, APIHandler(handler), handlerOperation[TReq, TResp, Empty](true))
+}
+
+// HandleAPIWithUriParams is HandleREST for APIHandlerWithUriParams.
+func HandleAPIWithUriParams[TReq, TResp, TUri any](r Router, method, relativePath string, handler func(context.Context, *TReq, *TUri) (TResp, error)) gin.IRoutes {
+	return Handle(r, method, relativePath, APIHandlerWithUriParams(handler), handlerOperation[TReq, TResp, TUri](true))
+}
@@ -0,0 +1,233 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/ospiper/ginx"
+	"github.com/ospiper/ginx/dbx"
+)
+
+// ActorContextKey is the key the caller is stored under for the audit log, usually by an
+// authentication middleware calling gin.Context.Set.
+const ActorContextKey = "ginx.rest.actor"
+
+// ContextWithActor returns a copy of ctx carrying actor.
+func ContextWithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, ActorContextKey, actor)
+}
+
+// ActorFromContext returns the actor carried by ctx.
+func ActorFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	actor, _ := ctx.Value(ActorContextKey).(string)
+	return actor
+}
+
+// requestIDFromContext returns the id ginx.RequestContext copied into ctx, if any.
+func requestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(ginx.RequestIDContextKey).(string)
+	return id
+}
+
+// AuditEntry records a change to a record: its fields before and after, by JSON name.
+// The time of the change is CreatedAt.
+type AuditEntry struct {
+	dbx.Permanent
+	Resource string `json:"resource" gorm:"index:idx_audit_record"`
+	// RecordID is the key of the record as JSON, without the quotes of strings.
+	RecordID  string        `json:"record_id" gorm:"index:idx_audit_record"`
+	Action    string        `json:"action"`
+	Actor     string        `json:"actor"`
+	RequestID string        `json:"request_id"`
+	Changes   []AuditChange `json:"changes" gorm:"serializer:json"`
+}
+
+func (AuditEntry) NewWithID(id int64) AuditEntry {
+	return AuditEntry{Permanent: dbx.Permanent{ID: id}}
+}
+
+// AuditChange is the change of a field, null when it was or is no longer set.
+type AuditChange struct {
+	Field string          `json:"field"`
+	Old   json.RawMessage `json:"old"`
+	New   json.RawMessage `json:"new"`
+}
+
+// Auditor stores the entries of the audit log.
+type Auditor struct {
+	Provider Provider[AuditEntry]
+	// Ignore lists the fields left out of the changes, by JSON name.
+	Ignore []string
+}
+
+// NewAuditor returns an auditor storing its entries in db, ignoring updated_at.
+func NewAuditor(db *gorm.DB) *Auditor {
+	return &Auditor{
+		Provider: NewProvider[AuditEntry](db),
+		Ignore:   []string{"updated_at"},
+	}
+}
+
+func (a *Auditor) Migrate() error {
+	return a.Provider.Migrate()
+}
+
+// record stores the action on the record keyed by key of resource, before and after being nil
+// for creations and deletions respectively.
+func (a *Auditor) record(ctx context.Context, resource, action string, key, before, after any) error {
+	id, err := auditKey(key)
+	if err != nil {
+		return err
+	}
+	changes, err := a.diff(before, after)
+	if err != nil {
+		return err
+	}
+	return a.Provider.Insert(ctx, &AuditEntry{
+		Resource:  resource,
+		RecordID:  id,
+		Action:    action,
+		Actor:     ActorFromContext(ctx),
+		RequestID: requestIDFromContext(ctx),
+		Changes:   changes,
+	})
+}
+
+// diff returns the fields of before and after which differ, in order of name.
+func (a *Auditor) diff(before, after any) ([]AuditChange, error) {
+	old, err := strip(before, a.Ignore)
+	if err != nil {
+		return nil, err
+	}
+	cur, err := strip(after, a.Ignore)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for name := range old {
+		names = append(names, name)
+	}
+	for name := range cur {
+		if _, ok := old[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	changes := make([]AuditChange, 0, len(names))
+	for _, name := range names {
+		if !bytes.Equal(old[name], cur[name]) {
+			changes = append(changes, AuditChange{Field: name, Old: old[name], New: cur[name]})
+		}
+	}
+	return changes, nil
+}
+
+// auditKey renders key as AuditEntry.RecordID.
+func auditKey(key any) (string, error) {
+	b, err := json.Marshal(key)
+	if err != nil {
+		return "", err
+	}
+	var s string
+	if json.Unmarshal(b, &s) == nil {
+		return s, nil
+	}
+	return string(b), nil
+}
+
+// AuditingProvider is AuditingProviderOf for models keyed by int64.
+type AuditingProvider[T dbx.ModelStruct[T]] = AuditingProviderOf[T, int64]
+
+// AuditingProviderOf decorates a provider to record the records it inserts, updates and deletes
+// in the audit log, in the same transaction as the change. Updated and deleted records are read
+// before the change to diff them. Records linked by AppendAssoc and friends are not recorded.
+type AuditingProviderOf[T dbx.ModelStructOf[T, ID], ID comparable] struct {
+	ObservingProviderOf[T, ID]
+	// Resource names the records in the entries. ResourceControllerOf looks them up by its
+	// resource name, which it must be for them to be served.
+	Resource string
+
+	auditor *Auditor
+}
+
+func NewAuditingProvider[T dbx.ModelStruct[T]](p Provider[T], auditor *Auditor, resource string) *AuditingProvider[T] {
+	return NewAuditingProviderOf(p, auditor, resource)
+}
+
+// NewAuditingProviderOf records the changes made through p with auditor as changes of resource.
+func NewAuditingProviderOf[T dbx.ModelStructOf[T, ID], ID comparable](p ProviderOf[T, ID], auditor *Auditor, resource string) *AuditingProviderOf[T, ID] {
+	ap := &AuditingProviderOf[T, ID]{Resource: resource, auditor: auditor}
+	ap.ObservingProviderOf = *NewObservingProviderOf(p, ap.record)
+	return ap
+}
+
+func (p *AuditingProviderOf[T, ID]) record(ctx context.Context, ch Change[T, ID]) error {
+	return p.auditor.record(ctx, p.Resource, ch.Type, ch.Key, ch.Before, ch.After)
+}
+
+// audit serves the audit log of a record, paged and sorted like the list route, to the callers
+// who may read the record. Changes to fields they may not read are left out. The log of deleted
+// records is only available through Auditor.Provider.
+func (r *ResourceControllerOf[T, ID]) audit(c *gin.Context) {
+	id, err := bindKey[ID](c, r.KeySeparator, "id")
+	if err != nil {
+		writer(c).Error(c, ginx.BindError(err))
+		return
+	}
+	base := ginx.RequestContext(c)
+	ctx := r.scope(base)
+	v, err := r.Provider.FindOne(ctx, id)
+	if err != nil {
+		writer(c).Error(c, err)
+		return
+	}
+	if r.Policy != nil && !r.Policy.CanRead(ctx, v) {
+		writer(c).Error(c, ErrForbidden)
+		return
+	}
+	cond, err := BuildSimpleRestConditions(c)
+	if err != nil {
+		writer(c).Error(c, ginx.BindError(err))
+		return
+	}
+	key, err := auditKey(id)
+	if err != nil {
+		writer(c).Error(c, err)
+		return
+	}
+	cond.Filters = append(cond.Filters, Eq("resource", r.resource()), Eq("record_id", key))
+	// the scope of the request restricts T, not the entries
+	entries, err := r.Audit.Provider.Find(base, cond)
+	if err != nil {
+		writer(c).Error(c, err)
+		return
+	}
+	cnt, err := r.Audit.Provider.Count(base, cond.Filters)
+	if err != nil {
+		writer(c).Error(c, err)
+		return
+	}
+	if hidden := r.guard().hidden(ctx); len(hidden) > 0 {
+		for _, e := range entries {
+			e.Changes = slices.DeleteFunc(e.Changes, func(ch AuditChange) bool {
+				return slices.Contains(hidden, ch.Field)
+			})
+		}
+	}
+	code, hd := PaginationHeader(cond.Pagination, cnt)
+	if hd != "" {
+		c.Header("Content-Range", hd)
+	}
+	writer(c).List(c, code, entries, cnt)
+}
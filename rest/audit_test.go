@@ -0,0 +1,97 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/assert/v2"
+
+	"github.com/ospiper/ginx"
+	"github.com/ospiper/ginx/dbx"
+)
+
+type testLedger struct {
+	dbx.Model
+	Account string `json:"account"`
+	Balance int    `json:"balance" access:"read=admin"`
+}
+
+func (testLedger) NewWithID(id int64) testLedger {
+	return testLedger{Model: dbx.Model{ID: id}}
+}
+
+func TestResourceControllerAudit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	auditor := NewAuditor(testDB)
+	assert.Equal(t, nil, auditor.Migrate())
+	provider := NewAuditingProvider(NewProvider[testLedger](testDB), auditor, "ledgers")
+	assert.Equal(t, nil, provider.Migrate())
+	app := gin.New()
+	app.Use(ginx.RequestID(), func(c *gin.Context) {
+		c.Set(ActorContextKey, c.GetHeader("X-Actor"))
+		c.Set(RoleContextKey, c.GetHeader("X-Role"))
+	})
+	ctrl := &ResourceController[testLedger]{Provider: provider, Group: app.Group("/ledgers"), Audit: auditor}
+	ctrl.Register()
+	serve := func(method, target, body, role string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		req.Header.Set("X-Actor", "alice")
+		req.Header.Set("X-Role", role)
+		req.Header.Set("X-Request-ID", "req-"+method)
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve(http.MethodPost, "/ledgers", `{"account":"a","balance":5}`, "admin")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var v testLedger
+	assert.Equal(t, nil, json.Unmarshal(w.Body.Bytes(), &v))
+	uri := "/ledgers/" + strconv.FormatInt(v.ID, 10)
+	w = serve(http.MethodPut, uri, `{"account":"b","balance":5}`, "admin")
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	w = serve(http.MethodGet, uri+"/audit", "", "admin")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var entries []AuditEntry
+	assert.Equal(t, nil, json.Unmarshal(w.Body.Bytes(), &entries))
+	assert.Equal(t, 2, len(entries))
+	assert.Equal(t, EventCreated, entries[0].Action)
+	assert.Equal(t, "alice", entries[0].Actor)
+	assert.Equal(t, "req-POST", entries[0].RequestID)
+	assert.Equal(t, strconv.FormatInt(v.ID, 10), entries[0].RecordID)
+	created := map[string]string{}
+	for _, ch := range entries[0].Changes {
+		assert.Equal(t, "null", string(ch.Old))
+		created[ch.Field] = string(ch.New)
+	}
+	assert.Equal(t, `"a"`, created["account"])
+	assert.Equal(t, `5`, created["balance"])
+	assert.Equal(t, EventUpdated, entries[1].Action)
+	assert.Equal(t, "req-PUT", entries[1].RequestID)
+	assert.Equal(t, []AuditChange{{Field: "account", Old: json.RawMessage(`"a"`), New: json.RawMessage(`"b"`)}}, entries[1].Changes)
+
+	// changes to fields the caller may not read are left out
+	w = serve(http.MethodGet, uri+"/audit", "", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, false, strings.Contains(w.Body.String(), `"balance"`))
+
+	w = serve(http.MethodDelete, uri, "", "admin")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodGet, uri+"/audit", "", "admin").Code)
+	deleted, err := auditor.Provider.FindFirst(context.Background(), &FindConditions{
+		Filters: []FilterFunc{Eq("resource", "ledgers"), Eq("action", EventDeleted)},
+	})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "req-DELETE", deleted.RequestID)
+	assert.Equal(t, `"b"`, string(deleted.Changes[0].Old))
+}
@@ -0,0 +1,145 @@
+package rest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ospiper/ginx/dbx"
+)
+
+// Types of ChangeEvent.
+const (
+	EventCreated = "created"
+	EventUpdated = "updated"
+	EventDeleted = "deleted"
+)
+
+// subscriberBuffer is the number of events a subscriber may lag behind before it is dropped.
+const subscriberBuffer = 64
+
+// ChangeEvent is a change to a record of T published by a PublishingProviderOf.
+type ChangeEvent[T any, ID comparable] struct {
+	// Seq numbers the events of a broker from 1, in the order they were published.
+	Seq  uint64
+	Type string
+	Key  ID
+	// Record is the record after the change, or before it for deletions. Subscribers share it
+	// and must not modify it.
+	Record *T
+}
+
+// Broker is BrokerOf for models keyed by int64.
+type Broker[T dbx.ModelStruct[T]] = BrokerOf[T, int64]
+
+// BrokerOf fans the changes to the records of T out to in-process subscribers, keeping the last
+// events for subscribers resuming after a disconnection. Subscribers which do not keep up are
+// dropped rather than slowing down publishers.
+type BrokerOf[T dbx.ModelStructOf[T, ID], ID comparable] struct {
+	mu  sync.Mutex
+	seq uint64
+	// replay is a ring of the last events, start is the index of the oldest one
+	replay      []ChangeEvent[T, ID]
+	start, size int
+	subscribers map[chan ChangeEvent[T, ID]]struct{}
+}
+
+func NewBroker[T dbx.ModelStruct[T]](replay int) *Broker[T] {
+	return NewBrokerOf[T, int64](replay)
+}
+
+// NewBrokerOf returns a broker keeping the last replay events for resuming subscribers.
+func NewBrokerOf[T dbx.ModelStructOf[T, ID], ID comparable](replay int) *BrokerOf[T, ID] {
+	return &BrokerOf[T, ID]{
+		replay:      make([]ChangeEvent[T, ID], replay),
+		subscribers: make(map[chan ChangeEvent[T, ID]]struct{}),
+	}
+}
+
+// Publish numbers the event of type on the record v keyed by key, and sends it to every subscriber.
+func (b *BrokerOf[T, ID]) Publish(typ string, key ID, v *T) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.seq++
+	ev := ChangeEvent[T, ID]{Seq: b.seq, Type: typ, Key: key, Record: v}
+	if len(b.replay) > 0 {
+		b.replay[(b.start+b.size)%len(b.replay)] = ev
+		if b.size < len(b.replay) {
+			b.size++
+		} else {
+			b.start = (b.start + 1) % len(b.replay)
+		}
+	}
+	for ch := range b.subscribers {
+		select {
+		case ch <- ev:
+		default:
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Subscribe returns the events to come, and when resume is set the kept events published after
+// the one numbered after. The channel is closed when the subscriber falls too far behind; it can
+// then subscribe again, resuming after the last event it got. cancel ends the subscription.
+//
+// Events older than the kept ones are lost, as are all events when after is ahead of the broker,
+// e.g. because it was numbered by a previous process.
+func (b *BrokerOf[T, ID]) Subscribe(after uint64, resume bool) (replay []ChangeEvent[T, ID], events <-chan ChangeEvent[T, ID], cancel func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if resume && after <= b.seq {
+		for i := 0; i < b.size; i++ {
+			if ev := b.replay[(b.start+i)%len(b.replay)]; ev.Seq > after {
+				replay = append(replay, ev)
+			}
+		}
+	}
+	ch := make(chan ChangeEvent[T, ID], subscriberBuffer)
+	b.subscribers[ch] = struct{}{}
+	return replay, ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subscribers[ch]; ok {
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// PublishingProvider is PublishingProviderOf for models keyed by int64.
+type PublishingProvider[T dbx.ModelStruct[T]] = PublishingProviderOf[T, int64]
+
+// PublishingProviderOf decorates a provider to publish the records it inserts, updates and deletes
+// to a broker, once the transaction they are written in commits. Updated records are read back
+// to publish them whole, deleted ones are read before being deleted. Records linked by AppendAssoc
+// and friends are not published.
+type PublishingProviderOf[T dbx.ModelStructOf[T, ID], ID comparable] struct {
+	ObservingProviderOf[T, ID]
+
+	broker *BrokerOf[T, ID]
+}
+
+func NewPublishingProvider[T dbx.ModelStruct[T]](p Provider[T], broker *Broker[T]) *PublishingProvider[T] {
+	return NewPublishingProviderOf(p, broker)
+}
+
+// NewPublishingProviderOf publishes the writes made through p to broker.
+func NewPublishingProviderOf[T dbx.ModelStructOf[T, ID], ID comparable](p ProviderOf[T, ID], broker *BrokerOf[T, ID]) *PublishingProviderOf[T, ID] {
+	pp := &PublishingProviderOf[T, ID]{broker: broker}
+	pp.ObservingProviderOf = *NewObservingProviderOf(p, pp.publish)
+	return pp
+}
+
+// publish publishes a copy of the record of ch once the transaction of ctx commits.
+func (p *PublishingProviderOf[T, ID]) publish(ctx context.Context, ch Change[T, ID]) error {
+	v := ch.After
+	if v == nil {
+		v = ch.Before
+	}
+	cp := *v
+	dbx.AfterCommit(ctx, func() {
+		p.broker.Publish(ch.Type, ch.Key, &cp)
+	})
+	return nil
+}
@@ -0,0 +1,337 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/ospiper/ginx/dbx"
+)
+
+// CachingProvider is CachingProviderOf for models keyed by int64.
+type CachingProvider[T dbx.ModelStruct[T]] = CachingProviderOf[T, int64]
+
+// CachingProviderOf decorates a provider with a read-through cache. FindOne is always cached,
+// Find, FindFirst and Count only when CacheLists is set. Lookups are keyed by the query they
+// run, the policy scope and the tenant of the context included, and concurrent misses of a
+// key share a single query, which goes on when the caller which started it is cancelled.
+//
+// Every write through the provider invalidates every entry, when it is made and again once its
+// transaction commits, so that lookups made in between do not keep the rows from before the
+// commit. Writes made elsewhere, e.g. by another process sharing the database, are only seen
+// once the entries expire. Lookups made in a transaction bypass the cache. Records are copied
+// shallowly: callers must not modify the slices and maps of the records they get.
+type CachingProviderOf[T dbx.ModelStructOf[T, ID], ID comparable] struct {
+	ProviderOf[T, ID]
+	// CacheLists caches Find, FindFirst and Count too.
+	CacheLists bool
+
+	cache Cache[CacheEntry[T]]
+	// generation is part of every key, writes bump it
+	generation atomic.Uint64
+	flight     flight[CacheEntry[T]]
+	hits       atomic.Uint64
+	misses     atomic.Uint64
+}
+
+// CacheEntry is what CachingProviderOf stores: copies of the records of a lookup, or a count.
+type CacheEntry[T any] struct {
+	Records []T
+	Count   int64
+}
+
+// CacheStats counts the lookups of a CachingProviderOf answered from the cache.
+type CacheStats struct {
+	Hits   uint64
+	Misses uint64
+}
+
+func NewCachingProvider[T dbx.ModelStruct[T]](p Provider[T], cache Cache[CacheEntry[T]]) *CachingProvider[T] {
+	return NewCachingProviderOf(p, cache)
+}
+
+// NewCachingProviderOf caches the lookups of p in cache, an LRUCache of 1000 entries
+// expiring after a minute when nil.
+func NewCachingProviderOf[T dbx.ModelStructOf[T, ID], ID comparable](p ProviderOf[T, ID], cache Cache[CacheEntry[T]]) *CachingProviderOf[T, ID] {
+	if cache == nil {
+		cache = NewLRUCache[CacheEntry[T]](1000, time.Minute)
+	}
+	return &CachingProviderOf[T, ID]{ProviderOf: p, cache: cache}
+}
+
+func (p *CachingProviderOf[T, ID]) Stats() CacheStats {
+	return CacheStats{Hits: p.hits.Load(), Misses: p.misses.Load()}
+}
+
+func (p *CachingProviderOf[T, ID]) keyOf(ctx context.Context, v *T) (ID, error) {
+	return keyOf(ctx, p.ProviderOf, v)
+}
+
+// Invalidate drops every cached entry, e.g. after writing to the table outside of the provider.
+func (p *CachingProviderOf[T, ID]) Invalidate() {
+	p.generation.Add(1)
+}
+
+// invalidate drops every cached entry now and once the transaction of ctx commits.
+func (p *CachingProviderOf[T, ID]) invalidate(ctx context.Context) {
+	p.Invalidate()
+	dbx.AfterCommit(ctx, p.Invalidate)
+}
+
+// key identifies a lookup of kind in ctx by the SQL its conditions and the scope of ctx render
+// to. Lookups by id without a scope skip rendering it.
+func (p *CachingProviderOf[T, ID]) key(ctx context.Context, kind string, conditions *FindConditions, id any) (string, error) {
+	tenant, _ := dbx.TenantFromContext(ctx)
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s:%d:%s", kind, p.generation.Load(), tenant)
+	scope := ScopeFromContext(ctx)
+	if len(scope) > 0 || conditions != nil {
+		var cond FindConditions
+		if conditions != nil {
+			cond = *conditions
+		}
+		preloads := cond.Preloads
+		cond.Preloads = nil
+		clauses, err := ApplyFilterFunc(scope)
+		if err != nil {
+			return "", err
+		}
+		var m T
+		tx := p.GetDB().Session(&gorm.Session{DryRun: true, NewDB: true}).Model(&m).Clauses(clauses...)
+		tx, err = cond.Apply(tx)
+		if err != nil {
+			return "", err
+		}
+		stmt := tx.Find(&[]T{}).Statement
+		if stmt.Error != nil {
+			return "", stmt.Error
+		}
+		fmt.Fprintf(&b, ":%s:%v:%v", stmt.SQL.String(), stmt.Vars, preloads)
+	}
+	if id != nil {
+		fmt.Fprintf(&b, ":%v", id)
+	}
+	return b.String(), nil
+}
+
+// load returns the entry under key, running fn to fill it on a miss.
+func (p *CachingProviderOf[T, ID]) load(ctx context.Context, key string, fn func(ctx context.Context) (CacheEntry[T], error)) (CacheEntry[T], error) {
+	if e, ok := p.cache.Get(key); ok {
+		p.hits.Add(1)
+		return e, nil
+	}
+	p.misses.Add(1)
+	return p.flight.do(ctx, key, func(ctx context.Context) (CacheEntry[T], error) {
+		e, err := fn(ctx)
+		if err == nil {
+			p.cache.Set(key, e)
+		}
+		return e, err
+	})
+}
+
+// cached reports whether lookups in ctx may use the cache.
+func cached(ctx context.Context) bool {
+	_, ok := dbx.TxFromContext(ctx)
+	return !ok
+}
+
+func (p *CachingProviderOf[T, ID]) FindOne(ctx context.Context, id ID) (*T, error) {
+	if !cached(ctx) {
+		return p.ProviderOf.FindOne(ctx, id)
+	}
+	key, err := p.key(ctx, "one", nil, id)
+	if err != nil {
+		return nil, err
+	}
+	e, err := p.load(ctx, key, func(ctx context.Context) (CacheEntry[T], error) {
+		v, err := p.ProviderOf.FindOne(ctx, id)
+		if err != nil {
+			return CacheEntry[T]{}, err
+		}
+		return CacheEntry[T]{Records: []T{*v}}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	v := e.Records[0]
+	return &v, nil
+}
+
+func (p *CachingProviderOf[T, ID]) Find(ctx context.Context, conditions *FindConditions) ([]*T, error) {
+	if !p.CacheLists || !cached(ctx) {
+		return p.ProviderOf.Find(ctx, conditions)
+	}
+	key, err := p.key(ctx, "find", conditions, nil)
+	if err != nil {
+		return nil, err
+	}
+	e, err := p.load(ctx, key, func(ctx context.Context) (CacheEntry[T], error) {
+		vs, err := p.ProviderOf.Find(ctx, conditions)
+		if err != nil {
+			return CacheEntry[T]{}, err
+		}
+		return CacheEntry[T]{Records: values(vs)}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pointers(e.Records), nil
+}
+
+func (p *CachingProviderOf[T, ID]) FindFirst(ctx context.Context, conditions *FindConditions) (*T, error) {
+	if !p.CacheLists || !cached(ctx) {
+		return p.ProviderOf.FindFirst(ctx, conditions)
+	}
+	key, err := p.key(ctx, "first", conditions, nil)
+	if err != nil {
+		return nil, err
+	}
+	e, err := p.load(ctx, key, func(ctx context.Context) (CacheEntry[T], error) {
+		v, err := p.ProviderOf.FindFirst(ctx, conditions)
+		if err != nil {
+			return CacheEntry[T]{}, err
+		}
+		return CacheEntry[T]{Records: []T{*v}}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	v := e.Records[0]
+	return &v, nil
+}
+
+func (p *CachingProviderOf[T, ID]) Count(ctx context.Context, filters []FilterFunc) (int64, error) {
+	if !p.CacheLists || !cached(ctx) {
+		return p.ProviderOf.Count(ctx, filters)
+	}
+	key, err := p.key(ctx, "count", &FindConditions{Filters: filters}, nil)
+	if err != nil {
+		return 0, err
+	}
+	e, err := p.load(ctx, key, func(ctx context.Context) (CacheEntry[T], error) {
+		cnt, err := p.ProviderOf.Count(ctx, filters)
+		return CacheEntry[T]{Count: cnt}, err
+	})
+	return e.Count, err
+}
+
+func values[T any](vs []*T) []T {
+	ret := make([]T, len(vs))
+	for i, v := range vs {
+		ret[i] = *v
+	}
+	return ret
+}
+
+func pointers[T any](vs []T) []*T {
+	ret := make([]*T, len(vs))
+	for i := range vs {
+		v := vs[i]
+		ret[i] = &v
+	}
+	return ret
+}
+
+func (p *CachingProviderOf[T, ID]) AppendAssoc(ctx context.Context, parentModel any, assocName string, vs ...*T) error {
+	defer p.invalidate(ctx)
+	return p.ProviderOf.AppendAssoc(ctx, parentModel, assocName, vs...)
+}
+
+func (p *CachingProviderOf[T, ID]) ReplaceAssoc(ctx context.Context, parentModel any, assocName string, vs ...*T) error {
+	defer p.invalidate(ctx)
+	return p.ProviderOf.ReplaceAssoc(ctx, parentModel, assocName, vs...)
+}
+
+func (p *CachingProviderOf[T, ID]) RemoveAssoc(ctx context.Context, parentModel any, assocName string, vs ...*T) error {
+	defer p.invalidate(ctx)
+	return p.ProviderOf.RemoveAssoc(ctx, parentModel, assocName, vs...)
+}
+
+func (p *CachingProviderOf[T, ID]) Insert(ctx context.Context, v *T) error {
+	defer p.invalidate(ctx)
+	return p.ProviderOf.Insert(ctx, v)
+}
+
+func (p *CachingProviderOf[T, ID]) InsertMany(ctx context.Context, vs []*T) error {
+	defer p.invalidate(ctx)
+	return p.ProviderOf.InsertMany(ctx, vs)
+}
+
+func (p *CachingProviderOf[T, ID]) InsertBatch(ctx context.Context, vs []*T, batchSize int) error {
+	defer p.invalidate(ctx)
+	return p.ProviderOf.InsertBatch(ctx, vs, batchSize)
+}
+
+func (p *CachingProviderOf[T, ID]) Update(ctx context.Context, id ID, v *T) error {
+	defer p.invalidate(ctx)
+	return p.ProviderOf.Update(ctx, id, v)
+}
+
+func (p *CachingProviderOf[T, ID]) UpdateFields(ctx context.Context, id ID, fields map[string]any) (*T, error) {
+	defer p.invalidate(ctx)
+	return p.ProviderOf.UpdateFields(ctx, id, fields)
+}
+
+func (p *CachingProviderOf[T, ID]) Delete(ctx context.Context, id ID) error {
+	defer p.invalidate(ctx)
+	return p.ProviderOf.Delete(ctx, id)
+}
+
+func (p *CachingProviderOf[T, ID]) DeleteMany(ctx context.Context, ids []ID) ([]DeleteResult[ID], error) {
+	defer p.invalidate(ctx)
+	return p.ProviderOf.DeleteMany(ctx, ids)
+}
+
+var errFlightPanicked = errors.New("cache: lookup panicked")
+
+// flight runs one call per key at a time, handing its result to the callers waiting for it.
+type flight[V any] struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall[V]
+}
+
+type flightCall[V any] struct {
+	done chan struct{}
+	val  V
+	err  error
+}
+
+// do runs fn under key unless a call of key is in flight, in which case it waits for its result
+// or for ctx to be done. fn runs on ctx without its cancellation, so that the caller which
+// started it going away does not fail the others.
+func (f *flight[V]) do(ctx context.Context, key string, fn func(ctx context.Context) (V, error)) (V, error) {
+	f.mu.Lock()
+	if c, ok := f.calls[key]; ok {
+		f.mu.Unlock()
+		select {
+		case <-c.done:
+			return c.val, c.err
+		case <-ctx.Done():
+			var zero V
+			return zero, ctx.Err()
+		}
+	}
+	if f.calls == nil {
+		f.calls = make(map[string]*flightCall[V])
+	}
+	// callers waiting for a call which panicked get this error rather than a zero value
+	c := &flightCall[V]{done: make(chan struct{}), err: errFlightPanicked}
+	f.calls[key] = c
+	f.mu.Unlock()
+
+	defer func() {
+		f.mu.Lock()
+		delete(f.calls, key)
+		f.mu.Unlock()
+		close(c.done)
+	}()
+	c.val, c.err = fn(context.WithoutCancel(ctx))
+	return c.val, c.err
+}
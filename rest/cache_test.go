@@ -0,0 +1,164 @@
+package rest
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+
+	"github.com/ospiper/ginx/dbx"
+)
+
+func TestLRUCache(t *testing.T) {
+	c := NewLRUCache[int](2, 0)
+	c.Set("a", 1)
+	c.Set("b", 2)
+	_, _ = c.Get("a")
+	c.Set("c", 3)
+	_, ok := c.Get("b")
+	assert.Equal(t, false, ok)
+	v, ok := c.Get("a")
+	assert.Equal(t, true, ok)
+	assert.Equal(t, 1, v)
+	c.Delete("a")
+	assert.Equal(t, 1, c.Len())
+
+	c = NewLRUCache[int](0, time.Millisecond)
+	c.Set("a", 1)
+	time.Sleep(2 * time.Millisecond)
+	_, ok = c.Get("a")
+	assert.Equal(t, false, ok)
+}
+
+func TestCachingProvider(t *testing.T) {
+	readings := NewProvider[testReading](testDB)
+	assert.Equal(t, nil, readings.Migrate())
+	ctx := context.Background()
+	r := &testReading{Sensor: "cached", Value: 1}
+	assert.Equal(t, nil, readings.Insert(ctx, r))
+	p := NewCachingProvider(readings, nil)
+	p.CacheLists = true
+
+	v, err := p.FindOne(ctx, r.ID)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, v.Value)
+	// behind the back of the cache
+	assert.Equal(t, nil, testDB.Model(r).Update("value", 2).Error)
+	v.Value = 99
+	v, err = p.FindOne(ctx, r.ID)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, v.Value)
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 1}, p.Stats())
+
+	// a scope is part of the key
+	_, err = p.FindOne(ContextWithScope(ctx, Eq("sensor", "other")), r.ID)
+	assert.Equal(t, ErrNotFound, err)
+
+	cond := &FindConditions{Filters: []FilterFunc{Eq("sensor", "cached")}}
+	cnt, err := p.Count(ctx, cond.Filters)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(1), cnt)
+	assert.Equal(t, nil, readings.Insert(ctx, &testReading{Sensor: "cached"}))
+	cnt, _ = p.Count(ctx, []FilterFunc{Eq("sensor", "cached")})
+	assert.Equal(t, int64(1), cnt)
+
+	_, err = p.UpdateFields(ctx, r.ID, map[string]any{"value": 3})
+	assert.Equal(t, nil, err)
+	v, _ = p.FindOne(ctx, r.ID)
+	assert.Equal(t, 3, v.Value)
+	vs, err := p.Find(ctx, cond)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, len(vs))
+
+	// a lookup racing the transaction of a write does not outlive its commit
+	err = dbx.Transaction(ctx, testDB, func(txCtx context.Context) error {
+		if _, err := p.UpdateFields(txCtx, r.ID, map[string]any{"value": 4}); err != nil {
+			return err
+		}
+		key, err := p.key(ctx, "one", nil, r.ID)
+		if err != nil {
+			return err
+		}
+		p.cache.Set(key, CacheEntry[testReading]{Records: []testReading{*v}})
+		return nil
+	})
+	assert.Equal(t, nil, err)
+	v, _ = p.FindOne(ctx, r.ID)
+	assert.Equal(t, 4, v.Value)
+
+	// filters parsed from the same query share a key, whatever order they come in
+	first, err := buildFilters(`{"sensor":"cached","value_neq":0,"id_between":[1,99999],"sensor_like":"c"}`)
+	assert.Equal(t, nil, err)
+	want, err := p.key(ctx, "find", &FindConditions{Filters: first}, nil)
+	assert.Equal(t, nil, err)
+	for range 20 {
+		filters, _ := buildFilters(`{"sensor":"cached","value_neq":0,"id_between":[1,99999],"sensor_like":"c"}`)
+		key, err := p.key(ctx, "find", &FindConditions{Filters: filters}, nil)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, want, key)
+	}
+}
+
+func TestFlight(t *testing.T) {
+	var f flight[int]
+	var calls atomic.Int32
+	release := make(chan struct{})
+	var entered, done sync.WaitGroup
+	results := make([]int, 5)
+	for i := range results {
+		entered.Add(1)
+		done.Add(1)
+		go func() {
+			defer done.Done()
+			entered.Done()
+			results[i], _ = f.do(context.Background(), "k", func(context.Context) (int, error) {
+				calls.Add(1)
+				<-release
+				return 7, nil
+			})
+		}()
+	}
+	entered.Wait()
+	// let the callers reach the call in flight
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	done.Wait()
+	assert.Equal(t, int32(1), calls.Load())
+	assert.Equal(t, []int{7, 7, 7, 7, 7}, results)
+
+	// the caller starting the call goes away: the call goes on for the others, while cancelled
+	// waiters go away too
+	ctx, cancel := context.WithCancel(context.Background())
+	release = make(chan struct{})
+	started := make(chan struct{})
+	var first int
+	var firstErr error
+	done.Add(1)
+	go func() {
+		defer done.Done()
+		first, firstErr = f.do(ctx, "k", func(ctx context.Context) (int, error) {
+			close(started)
+			<-release
+			return 8, ctx.Err()
+		})
+	}()
+	<-started
+	cancel()
+	_, err := f.do(ctx, "k", func(context.Context) (int, error) { return 0, nil })
+	assert.Equal(t, context.Canceled, err)
+	var second int
+	done.Add(1)
+	go func() {
+		defer done.Done()
+		second, _ = f.do(context.Background(), "k", func(context.Context) (int, error) { return 0, nil })
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	done.Wait()
+	assert.Equal(t, nil, firstErr)
+	assert.Equal(t, 8, first)
+	assert.Equal(t, 8, second)
+}
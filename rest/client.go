@@ -0,0 +1,271 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ospiper/ginx"
+)
+
+// Client is ClientOf for resources keyed by int64.
+type Client[T any] = ClientOf[T, int64]
+
+// ClientOf calls the routes a ResourceControllerOf serves for T at BaseURL, e.g.
+// http://orders.svc/api/orders. Failures are returned as *ginx.Error.
+type ClientOf[T any, ID comparable] struct {
+	BaseURL string
+	// HTTPClient sends the requests, http.DefaultClient when nil.
+	HTTPClient *http.Client
+	// Header is added to every request, e.g. Authorization.
+	Header http.Header
+	// KeySeparator must match the KeySeparator of the controller.
+	KeySeparator string
+}
+
+func NewClient[T any](baseURL string) *Client[T] {
+	return NewClientOf[T, int64](baseURL)
+}
+
+func NewClientOf[T any, ID comparable](baseURL string) *ClientOf[T, ID] {
+	return &ClientOf[T, ID]{BaseURL: baseURL}
+}
+
+// ListOptions are the simple-rest query parameters of a list request.
+type ListOptions struct {
+	Filter map[string]any
+	Orders []Order
+	// Range selects the records, the first 26 when nil as on the server.
+	Range *Range
+	Embed []string
+}
+
+// ListResult is a range of records out of Total matching ones.
+type ListResult[T any] struct {
+	Records []*T
+	Range   Range
+	Total   int64
+}
+
+var regContentRange = regexp.MustCompile(`^items (\d+)-(\d+)/(\d+)$`)
+
+func (c *ClientOf[T, ID]) List(ctx context.Context, opts *ListOptions) (*ListResult[T], error) {
+	query, err := opts.query()
+	if err != nil {
+		return nil, err
+	}
+	target := strings.TrimRight(c.BaseURL, "/")
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	var records []*T
+	res, err := c.do(ctx, http.MethodGet, target, nil, &records)
+	if err != nil {
+		return nil, err
+	}
+	ret := &ListResult[T]{Records: records, Total: int64(len(records))}
+	ret.Range.End = len(records) - 1
+	if m := regContentRange.FindStringSubmatch(res.Header.Get("Content-Range")); m != nil {
+		ret.Range.Start, _ = strconv.Atoi(m[1])
+		ret.Range.End, _ = strconv.Atoi(m[2])
+		ret.Total, _ = strconv.ParseInt(m[3], 10, 64)
+	}
+	return ret, nil
+}
+
+// All iterates over the records matching opts, listing size records at a time from opts.Range.Start.
+func (c *ClientOf[T, ID]) All(ctx context.Context, opts *ListOptions, size int) iter.Seq2[*T, error] {
+	return func(yield func(*T, error) bool) {
+		var o ListOptions
+		if opts != nil {
+			o = *opts
+		}
+		start := 0
+		if o.Range != nil {
+			start = o.Range.Start
+		}
+		for {
+			o.Range = &Range{Start: start, End: start + size - 1}
+			page, err := c.List(ctx, &o)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			for _, v := range page.Records {
+				if !yield(v, nil) {
+					return
+				}
+			}
+			start += len(page.Records)
+			if len(page.Records) == 0 || int64(start) >= page.Total {
+				return
+			}
+		}
+	}
+}
+
+func (c *ClientOf[T, ID]) Get(ctx context.Context, id ID) (*T, error) {
+	target, err := c.keyURL(id)
+	if err != nil {
+		return nil, err
+	}
+	ret := new(T)
+	_, err = c.do(ctx, http.MethodGet, target, nil, ret)
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func (c *ClientOf[T, ID]) Create(ctx context.Context, v *T) (*T, error) {
+	ret := new(T)
+	_, err := c.do(ctx, http.MethodPost, strings.TrimRight(c.BaseURL, "/"), v, ret)
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func (c *ClientOf[T, ID]) Update(ctx context.Context, id ID, v *T) (*T, error) {
+	target, err := c.keyURL(id)
+	if err != nil {
+		return nil, err
+	}
+	ret := new(T)
+	_, err = c.do(ctx, http.MethodPut, target, v, ret)
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func (c *ClientOf[T, ID]) Delete(ctx context.Context, id ID) error {
+	target, err := c.keyURL(id)
+	if err != nil {
+		return err
+	}
+	_, err = c.do(ctx, http.MethodDelete, target, nil, nil)
+	return err
+}
+
+// query encodes o the way BuildSimpleRestConditions reads it.
+func (o *ListOptions) query() (url.Values, error) {
+	query := make(url.Values)
+	if o == nil {
+		return query, nil
+	}
+	if len(o.Filter) > 0 {
+		b, err := json.Marshal(o.Filter)
+		if err != nil {
+			return nil, err
+		}
+		query.Set("filter", string(b))
+	}
+	if len(o.Orders) > 0 {
+		sort := make([]string, 0, 2*len(o.Orders))
+		for _, order := range o.Orders {
+			dir := "ASC"
+			if order.Desc {
+				dir = "DESC"
+			}
+			sort = append(sort, order.Column, dir)
+		}
+		b, _ := json.Marshal(sort)
+		query.Set("sort", string(b))
+	}
+	if o.Range != nil {
+		query.Set("range", fmt.Sprintf("[%d,%d]", o.Range.Start, o.Range.End))
+	}
+	if len(o.Embed) > 0 {
+		b, _ := json.Marshal(o.Embed)
+		query.Set("embed", string(b))
+	}
+	return query, nil
+}
+
+// keyURL addresses the record id, formatting its key fields like the routes of keyPath expect.
+func (c *ClientOf[T, ID]) keyURL(id ID) (string, error) {
+	v := reflect.ValueOf(id)
+	if !isKeyStruct[ID]() {
+		s, err := keySegment(v)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(c.BaseURL, "/") + "/" + s, nil
+	}
+	segments := make([]string, v.NumField())
+	for i := range segments {
+		s, err := keySegment(v.Field(i))
+		if err != nil {
+			return "", err
+		}
+		segments[i] = s
+	}
+	separator := "/"
+	if c.KeySeparator != "" {
+		separator = c.KeySeparator
+	}
+	return strings.TrimRight(c.BaseURL, "/") + "/" + strings.Join(segments, separator), nil
+}
+
+func keySegment(v reflect.Value) (string, error) {
+	if m, ok := v.Interface().(encoding.TextMarshaler); ok {
+		b, err := m.MarshalText()
+		if err != nil {
+			return "", err
+		}
+		return url.PathEscape(string(b)), nil
+	}
+	return url.PathEscape(fmt.Sprint(v.Interface())), nil
+}
+
+// do sends body as JSON and decodes the response into ret unless it is nil.
+func (c *ClientOf[T, ID]) do(ctx context.Context, method, target string, body any, ret any) (*http.Response, error) {
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		r = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, r)
+	if err != nil {
+		return nil, err
+	}
+	for k, vs := range c.Header {
+		req.Header[k] = vs
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= http.StatusBadRequest {
+		return nil, ginx.ResponseError(res)
+	}
+	if ret != nil && res.StatusCode != http.StatusNoContent {
+		if err := json.NewDecoder(res.Body).Decode(ret); err != nil {
+			return nil, fmt.Errorf("decode %s %s: %w", method, target, err)
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,93 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/assert/v2"
+
+	"github.com/ospiper/ginx"
+)
+
+func TestClient(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	provider := NewProvider[testOrder](testDB)
+	assert.Equal(t, nil, provider.Migrate())
+	app := gin.New()
+	RegisterResourceController(app.Group("/client/orders"), provider)
+	srv := httptest.NewServer(app)
+	defer srv.Close()
+	ctx := context.Background()
+	client := NewClient[testOrder](srv.URL + "/client/orders")
+
+	var ids []int64
+	for _, title := range []string{"client-a", "client-b", "client-c", "client-d", "client-e"} {
+		v, err := client.Create(ctx, &testOrder{Title: title})
+		assert.Equal(t, nil, err)
+		assert.Equal(t, title, v.Title)
+		ids = append(ids, v.ID)
+	}
+	v, err := client.Get(ctx, ids[0])
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "client-a", v.Title)
+	v, err = client.Update(ctx, ids[0], &testOrder{Title: "client-z"})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "client-z", v.Title)
+
+	page, err := client.List(ctx, &ListOptions{
+		Filter: map[string]any{"title_like": "client-"},
+		Orders: []Order{{Column: "title", Desc: true}},
+		Range:  &Range{Start: 0, End: 1},
+	})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(5), page.Total)
+	assert.Equal(t, Range{Start: 0, End: 1}, page.Range)
+	assert.Equal(t, 2, len(page.Records))
+	assert.Equal(t, "client-z", page.Records[0].Title)
+
+	var titles []string
+	for v, err := range client.All(ctx, &ListOptions{Filter: map[string]any{"title_like": "client-"}}, 2) {
+		assert.Equal(t, nil, err)
+		titles = append(titles, v.Title)
+	}
+	assert.Equal(t, []string{"client-z", "client-b", "client-c", "client-d", "client-e"}, titles)
+
+	assert.Equal(t, nil, client.Delete(ctx, ids[1]))
+	_, err = client.Get(ctx, ids[1])
+	var e *ginx.Error
+	assert.Equal(t, true, errors.As(err, &e))
+	assert.Equal(t, http.StatusNotFound, e.Status)
+	assert.Equal(t, "not_found", e.Code)
+}
+
+func TestClientCompositeKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	provider := NewProviderOf[testUserRole, testUserRoleKey](testDB)
+	assert.Equal(t, nil, provider.Migrate())
+	ctx := context.Background()
+	key := testUserRoleKey{UserID: 7, RoleID: 3}
+	assert.Equal(t, nil, provider.Insert(ctx, &testUserRole{UserID: 7, RoleID: 3, Note: "client"}))
+	defer provider.Delete(ctx, key)
+	app := gin.New()
+	RegisterResourceControllerOf(app.Group("/client/user-roles"), provider)
+	(&ResourceControllerOf[testUserRole, testUserRoleKey]{
+		Provider:     provider,
+		Group:        app.Group("/client/joined/user-roles"),
+		KeySeparator: ",",
+	}).Register()
+	srv := httptest.NewServer(app)
+	defer srv.Close()
+
+	v, err := NewClientOf[testUserRole, testUserRoleKey](srv.URL+"/client/user-roles").Get(ctx, key)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "client", v.Note)
+	joined := NewClientOf[testUserRole, testUserRoleKey](srv.URL + "/client/joined/user-roles")
+	joined.KeySeparator = ","
+	v, err = joined.Get(ctx, key)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "client", v.Note)
+}
@@ -1,22 +1,31 @@
 package rest
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
+	"reflect"
 	"regexp"
 	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 
+	"github.com/ospiper/ginx"
 	"github.com/ospiper/ginx/dbx"
+	"github.com/ospiper/ginx/util"
 )
 
-type IDQueryInPath struct {
-	ID int64 `uri:"id" binding:"required"`
+// IDInPath binds the :id segment of a route. IDs implementing encoding.TextUnmarshaler,
+// like uuid.UUID, are parsed with it.
+type IDInPath[ID comparable] struct {
+	ID ID `uri:"id,parser=encoding.TextUnmarshaler" binding:"required"`
 }
 
+type IDQueryInPath = IDInPath[int64]
+
 type PagedResults[T any] struct {
 	Records []*T  `json:"records"`
 	Total   int64 `json:"total"`
@@ -100,148 +109,371 @@ func BuildSimpleRestConditions(c *gin.Context) (*FindConditions, error) {
 	}, nil
 }
 
-type ResourceController[T dbx.ModelStruct[T]] struct {
+// ResourceController is ResourceControllerOf for models keyed by int64.
+type ResourceController[T dbx.ModelStruct[T]] = ResourceControllerOf[T, int64]
+
+type ResourceControllerOf[T dbx.ModelStructOf[T, ID], ID comparable] struct {
 	Name     string
-	Provider Provider[T]
+	Provider ProviderOf[T, ID]
 	Group    *gin.RouterGroup
+	// Hooks may implement any of the lifecycle hook interfaces, e.g. BeforeCreateHook[T].
+	Hooks any
+	// Policy authorizes every route and scopes the rows they can see, nil allows everything.
+	// If it implements FieldPolicy it also replaces the access tags of T.
+	Policy Policy[T]
+	// RejectProtectedFields answers writes to fields the caller may not write with 403
+	// instead of silently dropping them.
+	RejectProtectedFields bool
+	// CreateInput and UpdateInput bind the bodies of POST and PUT, BindModel when nil.
+	// Use BindInput or CopyInput to accept dedicated input types instead of T.
+	CreateInput Binder[T]
+	UpdateInput Binder[T]
+	// StreamLists writes list responses record by record from Provider.Iterate instead of
+	// loading the requested range first. Lists answered through another ResponseWriter than
+	// ginx.JSONWriter, e.g. envelopes, or in another format than JSON are loaded as usual.
+	StreamLists bool
+	// Import loads records in bulk at POST /import when set, see ImportQuery. Rows are bound and
+	// authorized like the bodies of POST and PUT.
+	Import bool
+	// Export serves every record the caller may list as CSV or NDJSON at GET /export when set.
+	Export bool
+	// Events serves the changes published to it at GET /events when set. Publish them by
+	// wrapping Provider in a PublishingProviderOf of the same broker.
+	Events *BrokerOf[T, ID]
+	// Audit serves the entries it stored for a record at GET /:id/audit when set. Record them by
+	// wrapping Provider in an AuditingProviderOf named after the resource.
+	Audit *Auditor
+	// KeySeparator addresses composite keys with a single :id segment joining the key fields
+	// with it, e.g. /1,2, instead of one segment per field.
+	KeySeparator string
 }
 
-func (c *ResourceController[T]) Register() {
-	RegisterResourceController(c.Group, c.Provider)
+func (r *ResourceControllerOf[T, ID]) Register() {
+	ginx.Validator()
+	uri := keyURI[ID](r.KeySeparator, "id")
+	body := reflect.TypeFor[T]()
+	r.handle(r.Group, http.MethodGet, "", r.list, "list", ginx.Operation{ // /drives
+		Query: reflect.TypeFor[SimpleRestQuery](),
+		List:  true,
+	})
+	if r.Export {
+		r.handle(r.Group, http.MethodGet, "/export", r.export, "export", ginx.Operation{ // /drives/export
+			Query:        reflect.TypeFor[ExportQuery](),
+			ContentTypes: []string{exportTypes["csv"], exportTypes["ndjson"]},
+		})
+	}
+	if r.Import {
+		r.handle(r.Group, http.MethodPost, "/import", r.importBody, "import", ginx.Operation{ // /drives/import
+			Query:    reflect.TypeFor[ImportQuery](),
+			Request:  reflect.TypeFor[[]T](),
+			Response: reflect.TypeFor[ImportReport](),
+		})
+	}
+	if r.Events != nil {
+		r.handle(r.Group, http.MethodGet, "/events", r.events, "events", ginx.Operation{ // /drives/events
+			Query:        reflect.TypeFor[EventsQuery](),
+			ContentTypes: []string{"text/event-stream"},
+		})
+	}
+	r.handle(r.Group, http.MethodPost, "", r.create, "create", ginx.Operation{ // /drives
+		Request: body,
+		Status:  http.StatusCreated,
+	})
+	idGroup := r.Group.Group(keyPath[ID](r.KeySeparator, "id"))
+	r.handle(idGroup, http.MethodGet, "", r.get, "get", ginx.Operation{URI: uri}) // /drives/:id
+	r.handle(idGroup, http.MethodPut, "", r.update, "update", ginx.Operation{     // /drives/:id
+		URI:     uri,
+		Request: body,
+		Status:  http.StatusCreated,
+	})
+	if r.Audit != nil {
+		r.handle(idGroup, http.MethodGet, "/audit", r.audit, "audit", ginx.Operation{ // /drives/:id/audit
+			URI:      uri,
+			Query:    reflect.TypeFor[SimpleRestQuery](),
+			Response: reflect.TypeFor[AuditEntry](),
+			List:     true,
+		})
+	}
+	if _, ok := util.As[dbx.PermanentModel](new(T)); ok {
+		methodNotAllowed(idGroup, http.MethodDelete, http.MethodGet, http.MethodPut)
+	} else {
+		r.handle(idGroup, http.MethodDelete, "", r.delete, "delete", ginx.Operation{ // /drives/:id
+			URI:    uri,
+			Status: http.StatusNoContent,
+		})
+	}
 }
 
-func NestedController[TBase dbx.ModelStruct[TBase], TNest dbx.ModelStruct[TNest]](baseController *ResourceController[TBase], nestController *ResourceController[TNest], name string) {
-	nestBaseGroup := baseController.Group.Group(":id").Group(strings.ToLower(name))
-	// controllers coping with nested (foreign key restraint) structures
-	// should not be nested in the API, it should directly be /tags/:id
-
-	// for OneToMany relations, is it necessary to keep interfaces like GET /tags and POST /tags
-	// to get all or to create a new tag even it might be meaningless?
-	nestBaseGroup.GET("", func(c *gin.Context) { // /drives/:id/tags
-		params := &IDQueryInPath{}
-		err := c.ShouldBindUri(params)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
-		}
-		cond, err := BuildSimpleRestConditions(c)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
-		}
-		var parentModel TBase
-		p := parentModel.NewWithID(params.ID)
-		records, err := nestController.Provider.FindAssoc(c, &p, name, cond)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-		cnt, err := nestController.Provider.CountAssoc(c, &p, name, cond.Filters)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-		code, hd := PaginationHeader(cond.Pagination, cnt)
-		if hd != "" {
-			c.Header("Content-Range", hd)
-		}
-		c.JSON(code, records)
-	})
+// methodNotAllowed answers method on group with 405, listing the allowed methods.
+func methodNotAllowed(group *gin.RouterGroup, method string, allowed ...string) {
+	ginx.Handle(group, method, "", func(c *gin.Context) {
+		c.Header("Allow", strings.Join(allowed, ", "))
+		writer(c).Error(c, ginx.NewError(http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed"))
+	}, ginx.Operation{Hidden: true})
 }
 
 func RegisterResourceController[T dbx.ModelStruct[T]](base *gin.RouterGroup, provider Provider[T]) *ResourceController[T] {
-	base.GET("", func(c *gin.Context) { // /drives
-		cond, err := BuildSimpleRestConditions(c)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
-		}
-		records, err := provider.Find(c, cond)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-		cnt, err := provider.Count(c, cond.Filters)
+	return RegisterResourceControllerOf(base, provider)
+}
+
+func RegisterResourceControllerOf[T dbx.ModelStructOf[T, ID], ID comparable](base *gin.RouterGroup, provider ProviderOf[T, ID]) *ResourceControllerOf[T, ID] {
+	ctrl := &ResourceControllerOf[T, ID]{
+		Name:     "resource",
+		Provider: provider,
+		Group:    base,
+	}
+	ctrl.Register()
+	return ctrl
+}
+
+// scope restricts the provider calls made with the returned context to the rows the policy allows.
+func (r *ResourceControllerOf[T, ID]) scope(ctx context.Context) context.Context {
+	if r.Policy == nil {
+		return ctx
+	}
+	return ContextWithScope(ctx, r.Policy.Scope(ctx)...)
+}
+
+// writer returns the ResponseWriter configured for the route, ginx.JSONWriter by default.
+func writer(c *gin.Context) ginx.ResponseWriter {
+	return ginx.WriterOf(c, ginx.JSONWriter{})
+}
+
+func (r *ResourceControllerOf[T, ID]) guard() *fieldGuard[T] {
+	return newFieldGuard[T](r.Policy, r.RejectProtectedFields)
+}
+
+// render writes v without the fields the caller may not read.
+func (r *ResourceControllerOf[T, ID]) render(c *gin.Context, code int, v *T) {
+	body, err := r.guard().view(c, v)
+	if err != nil {
+		writer(c).Error(c, err)
+		return
+	}
+	writer(c).Success(c, code, body)
+}
+
+// renderList is render for a page of records out of total.
+func (r *ResourceControllerOf[T, ID]) renderList(c *gin.Context, code int, vs []*T, total int64) {
+	body, err := r.guard().viewList(c, vs)
+	if err != nil {
+		writer(c).Error(c, err)
+		return
+	}
+	writer(c).List(c, code, body, total)
+}
+
+func (r *ResourceControllerOf[T, ID]) list(c *gin.Context) {
+	ctx := r.scope(ginx.RequestContext(c))
+	if r.Policy != nil && !r.Policy.CanList(ctx) {
+		writer(c).Error(c, ErrForbidden)
+		return
+	}
+	cond, err := BuildSimpleRestConditions(c)
+	if err != nil {
+		writer(c).Error(c, ginx.BindError(err))
+		return
+	}
+	if h, ok := r.Hooks.(BeforeListHook); ok {
+		err = h.BeforeList(ctx, cond)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			writer(c).Error(c, beforeHookError(err))
 			return
 		}
-		code, hd := PaginationHeader(cond.Pagination, cnt)
-		if hd != "" {
-			c.Header("Content-Range", hd)
-		}
-		c.JSON(code, records)
+	}
+	if _, ok := writer(c).(ginx.JSONWriter); ok && r.StreamLists && ginx.Negotiate(c) == binding.MIMEJSON {
+		r.streamList(ctx, c, cond)
+		return
+	}
+	records, err := r.Provider.Find(ctx, cond)
+	if err != nil {
+		writer(c).Error(c, err)
+		return
+	}
+	cnt, err := r.Provider.Count(ctx, cond.Filters)
+	if err != nil {
+		writer(c).Error(c, err)
+		return
+	}
+	code, hd := PaginationHeader(cond.Pagination, cnt)
+	if hd != "" {
+		c.Header("Content-Range", hd)
+	}
+	r.renderList(c, code, records, cnt)
+}
+
+// bind binds the body of a write request with binder, falling back to BindModel.
+func bind[T any](c *gin.Context, binder Binder[T], dst *T) error {
+	if err := ginx.CheckContentType(c); err != nil {
+		return err
+	}
+	if binder == nil {
+		return BindModel(c, dst)
+	}
+	return binder(c, dst)
+}
+
+func (r *ResourceControllerOf[T, ID]) create(c *gin.Context) {
+	var data T
+	err := bind(c, r.CreateInput, &data)
+	if err != nil {
+		writer(c).Error(c, ginx.BindError(err))
+		return
+	}
+	err = dbx.Transaction(r.scope(ginx.RequestContext(c)), r.Provider.GetDB(), func(ctx context.Context) error {
+		return r.insert(ctx, &data, nil)
 	})
-	base.POST("", func(c *gin.Context) { // /drives
-		var data T
-		err := c.ShouldBind(&data)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
-		}
-		err = provider.Insert(c, &data)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
+	if err != nil {
+		writer(c).Error(c, err)
+		return
+	}
+	r.render(c, http.StatusCreated, &data)
+}
+
+// insert authorizes and inserts data, running the create hooks around it. set, unless nil,
+// fills the fields the server decides, e.g. foreign keys, once the field guard has run.
+func (r *ResourceControllerOf[T, ID]) insert(ctx context.Context, data *T, set func(*T) error) error {
+	if err := r.guard().write(ctx, data); err != nil {
+		return err
+	}
+	if set != nil {
+		if err := set(data); err != nil {
+			return err
 		}
-		c.JSON(http.StatusCreated, data)
-	})
-	idGroup := base.Group(":id")
-	idGroup.GET("", func(c *gin.Context) { // /drives/:id
-		params := &IDQueryInPath{}
-		err := c.ShouldBindUri(params)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
+	}
+	if r.Policy != nil && !r.Policy.CanCreate(ctx, data) {
+		return ErrForbidden
+	}
+	if h, ok := r.Hooks.(BeforeCreateHook[T]); ok {
+		if err := h.BeforeCreate(ctx, data); err != nil {
+			return beforeHookError(err)
 		}
-		ret, err := provider.FindOne(c, params.ID)
+	}
+	if err := r.Provider.Insert(ctx, data); err != nil {
+		return err
+	}
+	if h, ok := r.Hooks.(AfterCreateHook[T]); ok {
+		return h.AfterCreate(ctx, data)
+	}
+	return nil
+}
+
+func (r *ResourceControllerOf[T, ID]) get(c *gin.Context) {
+	id, err := bindKey[ID](c, r.KeySeparator, "id")
+	if err != nil {
+		writer(c).Error(c, ginx.BindError(err))
+		return
+	}
+	ctx := r.scope(ginx.RequestContext(c))
+	ret, err := r.Provider.FindOne(ctx, id)
+	if err != nil {
+		writer(c).Error(c, err)
+		return
+	}
+	if r.Policy != nil && !r.Policy.CanRead(ctx, ret) {
+		writer(c).Error(c, ErrForbidden)
+		return
+	}
+	r.render(c, http.StatusOK, ret)
+}
+
+func (r *ResourceControllerOf[T, ID]) update(c *gin.Context) {
+	id, err := bindKey[ID](c, r.KeySeparator, "id")
+	if err != nil {
+		writer(c).Error(c, ginx.BindError(err))
+		return
+	}
+	var data T
+	err = bind(c, r.UpdateInput, &data)
+	if err != nil {
+		writer(c).Error(c, ginx.BindError(err))
+		return
+	}
+	err = dbx.Transaction(r.scope(ginx.RequestContext(c)), r.Provider.GetDB(), func(ctx context.Context) error {
+		old, err := r.Provider.FindOne(ctx, id)
 		if err != nil {
-			if errors.Is(err, ErrNotFound) {
-				c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
-				return
-			}
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
+			return err
 		}
-		c.JSON(http.StatusOK, ret)
+		return r.modify(ctx, id, old, &data, nil)
 	})
-	idGroup.PUT("", func(c *gin.Context) { // /drives/:id
-		params := &IDQueryInPath{}
-		err := c.ShouldBindUri(params)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
+	if err != nil {
+		writer(c).Error(c, err)
+		return
+	}
+	r.render(c, http.StatusCreated, &data)
+}
+
+// modify authorizes and writes data over old, stored under id, running the update hooks around
+// it. set is as for insert.
+func (r *ResourceControllerOf[T, ID]) modify(ctx context.Context, id ID, old, data *T, set func(*T) error) error {
+	if r.Policy != nil && !r.Policy.CanUpdate(ctx, old) {
+		return ErrForbidden
+	}
+	if err := r.guard().write(ctx, data); err != nil {
+		return err
+	}
+	if set != nil {
+		if err := set(data); err != nil {
+			return err
 		}
-		var data T
-		err = c.ShouldBind(&data)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
+	}
+	if h, ok := r.Hooks.(BeforeUpdateHook[T]); ok {
+		if err := h.BeforeUpdate(ctx, old, data); err != nil {
+			return beforeHookError(err)
 		}
-		err = provider.Update(c, params.ID, &data)
+	}
+	if err := r.Provider.Update(ctx, id, data); err != nil {
+		return err
+	}
+	if h, ok := r.Hooks.(AfterUpdateHook[T]); ok {
+		return h.AfterUpdate(ctx, old, data)
+	}
+	return nil
+}
+
+func (r *ResourceControllerOf[T, ID]) delete(c *gin.Context) {
+	id, err := bindKey[ID](c, r.KeySeparator, "id")
+	if err != nil {
+		writer(c).Error(c, ginx.BindError(err))
+		return
+	}
+	err = dbx.Transaction(r.scope(ginx.RequestContext(c)), r.Provider.GetDB(), func(ctx context.Context) error {
+		old, err := r.Provider.FindOne(ctx, id)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
+			return err
 		}
-		c.JSON(http.StatusCreated, data)
+		return r.remove(ctx, id, old)
 	})
-	idGroup.DELETE("", func(c *gin.Context) { // /drives/:id
-		params := &IDQueryInPath{}
-		err := c.ShouldBindUri(params)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
-		}
-		err = provider.Delete(c, params.ID)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
+	if err != nil {
+		writer(c).Error(c, err)
+		return
+	}
+	writer(c).Success(c, http.StatusNoContent, nil)
+}
+
+// remove authorizes and deletes old, stored under id, running the delete hooks around it.
+func (r *ResourceControllerOf[T, ID]) remove(ctx context.Context, id ID, old *T) error {
+	if r.Policy != nil && !r.Policy.CanDelete(ctx, old) {
+		return ErrForbidden
+	}
+	if h, ok := r.Hooks.(BeforeDeleteHook[T]); ok {
+		if err := h.BeforeDelete(ctx, old); err != nil {
+			return beforeHookError(err)
 		}
-		c.JSON(http.StatusNoContent, nil)
-	})
-	return &ResourceController[T]{
-		Name:     "resource",
-		Provider: provider,
-		Group:    base,
 	}
+	if err := r.Provider.Delete(ctx, id); err != nil {
+		return err
+	}
+	if h, ok := r.Hooks.(AfterDeleteHook[T]); ok {
+		return h.AfterDelete(ctx, old)
+	}
+	return nil
+}
+
+// beforeHookError rejects the request with 400 unless the hook chose a status itself.
+func beforeHookError(err error) error {
+	if _, ok := ginx.AsError(err); ok {
+		return err
+	}
+	return ginx.WrapError(http.StatusBadRequest, "rejected", err)
 }
@@ -0,0 +1,449 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/assert/v2"
+	"github.com/google/uuid"
+
+	"github.com/ospiper/ginx"
+	"github.com/ospiper/ginx/dbx"
+)
+
+func serveTest(h http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+type testOrderHooks struct {
+	created []string
+	sources []string
+}
+
+func (h *testOrderHooks) BeforeCreate(ctx context.Context, v *testOrder) error {
+	if v.Title == "" {
+		return errors.New("title required")
+	}
+	return nil
+}
+
+func (h *testOrderHooks) AfterCreate(ctx context.Context, v *testOrder) error {
+	h.created = append(h.created, v.Title)
+	if req, ok := ginx.RequestFromContext(ctx); ok {
+		h.sources = append(h.sources, req.Header.Get("X-Source"))
+	}
+	return nil
+}
+
+func (h *testOrderHooks) BeforeDelete(ctx context.Context, v *testOrder) error {
+	return WithStatus(http.StatusForbidden, errors.New("orders are kept"))
+}
+
+func TestResourceControllerHooks(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	provider := NewProvider[testOrder](testDB)
+	assert.Equal(t, nil, provider.Migrate())
+	app := gin.New()
+	hooks := &testOrderHooks{}
+	ctrl := &ResourceController[testOrder]{
+		Name:     "orders",
+		Provider: provider,
+		Group:    app.Group("/orders"),
+		Hooks:    hooks,
+	}
+	ctrl.Register()
+
+	w := serveTest(app, http.MethodPost, "/orders", `{}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(`{"title":"hooked"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Source", "import")
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, []string{"hooked"}, hooks.created)
+	// hooks see the request
+	assert.Equal(t, []string{"import"}, hooks.sources)
+
+	o, err := provider.FindFirst(context.Background(), &FindConditions{Filters: []FilterFunc{Eq("title", "hooked")}})
+	assert.Equal(t, nil, err)
+	w = serveTest(app, http.MethodDelete, "/orders/"+strconv.FormatInt(o.ID, 10), "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = serveTest(app, http.MethodDelete, "/orders/999999", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+type testOrderPolicy struct {
+	OpenPolicy[testOrder]
+}
+
+func (testOrderPolicy) CanDelete(context.Context, *testOrder) bool { return false }
+
+func (testOrderPolicy) Scope(context.Context) []FilterFunc {
+	return []FilterFunc{Eq("title", "visible")}
+}
+
+func TestResourceControllerPolicy(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	provider := NewProvider[testOrder](testDB)
+	assert.Equal(t, nil, provider.Migrate())
+	ctx := context.Background()
+	visible, hidden := &testOrder{Title: "visible"}, &testOrder{Title: "hidden"}
+	assert.Equal(t, nil, provider.InsertMany(ctx, []*testOrder{visible, hidden}))
+
+	app := gin.New()
+	ctrl := &ResourceController[testOrder]{
+		Name:     "orders",
+		Provider: provider,
+		Group:    app.Group("/orders"),
+		Policy:   testOrderPolicy{},
+	}
+	ctrl.Register()
+
+	w := serveTest(app, http.MethodGet, "/orders", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, false, strings.Contains(w.Body.String(), "hidden"))
+	w = serveTest(app, http.MethodGet, "/orders/"+strconv.FormatInt(hidden.ID, 10), "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = serveTest(app, http.MethodPut, "/orders/"+strconv.FormatInt(hidden.ID, 10), `{"title":"taken"}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = serveTest(app, http.MethodDelete, "/orders/"+strconv.FormatInt(visible.ID, 10), "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+type testEmployee struct {
+	dbx.Model
+	Name   string `json:"name"`
+	Salary int    `json:"salary" access:"read=hr|admin;write=hr"`
+}
+
+func (testEmployee) NewWithID(id int64) testEmployee {
+	return testEmployee{Model: dbx.Model{ID: id}}
+}
+
+func TestResourceControllerFieldAccess(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	provider := NewProvider[testEmployee](testDB)
+	assert.Equal(t, nil, provider.Migrate())
+	app := gin.New()
+	app.Use(func(c *gin.Context) {
+		c.Set(RoleContextKey, c.GetHeader("X-Role"))
+	})
+	RegisterResourceController(app.Group("/employees"), provider)
+
+	w := serveTest(app, http.MethodPost, "/employees", `{"name":"bob","salary":100}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, false, strings.Contains(w.Body.String(), "salary"))
+	e, err := provider.FindFirst(context.Background(), &FindConditions{Filters: []FilterFunc{Eq("name", "bob")}})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, e.Salary)
+
+	req := httptest.NewRequest(http.MethodPut, "/employees/"+strconv.FormatInt(e.ID, 10), strings.NewReader(`{"salary":200}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Role", "hr")
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, true, strings.Contains(w.Body.String(), `"salary":200`))
+}
+
+type testOrderInput struct {
+	Name *string `json:"name" binding:"required" copy:"Title"`
+}
+
+func TestResourceControllerInput(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	provider := NewProvider[testOrder](testDB)
+	assert.Equal(t, nil, provider.Migrate())
+	app := gin.New()
+	(&ResourceController[testOrder]{
+		Name:     "orders",
+		Provider: provider,
+		Group:    app.Group("/orders"),
+	}).Register()
+	(&ResourceController[testOrder]{
+		Name:        "orders",
+		Provider:    provider,
+		Group:       app.Group("/dto/orders"),
+		CreateInput: CopyInput[testOrderInput, testOrder](),
+	}).Register()
+
+	w := serveTest(app, http.MethodPost, "/orders", `{"id":424242,"title":"forged"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, false, strings.Contains(w.Body.String(), "424242"))
+	w = serveTest(app, http.MethodPost, "/dto/orders", `{"id":434343}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = serveTest(app, http.MethodPost, "/dto/orders", `{"id":434343,"name":"mapped"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, false, strings.Contains(w.Body.String(), "434343"))
+	assert.Equal(t, true, strings.Contains(w.Body.String(), `"title":"mapped"`))
+}
+
+func TestResourceControllerEnvelope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	provider := NewProvider[testOrder](testDB)
+	assert.Equal(t, nil, provider.Migrate())
+	app := gin.New()
+	app.Use(ginx.UseResponseWriter(ginx.EnvelopeWriter{}))
+	RegisterResourceController(app.Group("/orders"), provider)
+
+	w := serveTest(app, http.MethodGet, "/orders?range=[0,0]", "")
+	assert.Equal(t, true, strings.Contains(w.Body.String(), `"success":true`))
+	assert.Equal(t, true, strings.Contains(w.Body.String(), `"meta":{"total":`))
+	w = serveTest(app, http.MethodGet, "/orders/999999", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, true, strings.Contains(w.Body.String(), `"code":"not_found"`))
+}
+
+type testCountry struct {
+	dbx.Permanent
+	Code string `json:"code"`
+}
+
+func (testCountry) NewWithID(id int64) testCountry {
+	return testCountry{Permanent: dbx.Permanent{ID: id}}
+}
+
+func TestResourceControllerPermanent(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	provider := NewProvider[testCountry](testDB)
+	assert.Equal(t, nil, provider.Migrate())
+	app := gin.New()
+	RegisterResourceController(app.Group("/countries"), provider)
+
+	c := &testCountry{Code: "NZ"}
+	assert.Equal(t, nil, provider.Insert(context.Background(), c))
+	w := serveTest(app, http.MethodDelete, "/countries/"+strconv.FormatInt(c.ID, 10), "")
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, "GET, PUT", w.Header().Get("Allow"))
+	err := provider.Delete(context.Background(), c.ID)
+	assert.Equal(t, true, errors.Is(err, ErrNotDeletable))
+	assert.Equal(t, true, errors.Is(err, dbx.ErrPermanent))
+}
+
+type testDevice struct {
+	dbx.UUIDModel
+	Serial string `json:"serial"`
+}
+
+func (testDevice) NewWithID(id uuid.UUID) testDevice {
+	return testDevice{UUIDModel: dbx.UUIDModel{ID: id}}
+}
+
+func TestResourceControllerUUID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	provider := NewProviderOf[testDevice, uuid.UUID](testDB)
+	assert.Equal(t, nil, provider.Migrate())
+	app := gin.New()
+	RegisterResourceControllerOf(app.Group("/devices"), provider)
+
+	w := serveTest(app, http.MethodPost, "/devices", `{"serial":"sn-1"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var created testDevice
+	assert.Equal(t, nil, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.NotEqual(t, uuid.Nil, created.ID)
+	// clients cannot choose the key nor the timestamps
+	chosen := uuid.New()
+	w = serveTest(app, http.MethodPost, "/devices", `{"id":"`+chosen.String()+`","serial":"sn-2","created_at":"2000-01-01T00:00:00Z"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var other testDevice
+	assert.Equal(t, nil, json.Unmarshal(w.Body.Bytes(), &other))
+	assert.NotEqual(t, chosen, other.ID)
+	assert.NotEqual(t, 2000, other.CreatedAt.Year())
+	w = serveTest(app, http.MethodPut, "/devices/"+other.ID.String(), `{"id":"`+chosen.String()+`","serial":"sn-3"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	_, err := provider.FindOne(context.Background(), chosen)
+	assert.Equal(t, ErrNotFound, err)
+
+	w = serveTest(app, http.MethodGet, "/devices/"+created.ID.String(), "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, true, strings.Contains(w.Body.String(), `"serial":"sn-1"`))
+	w = serveTest(app, http.MethodGet, "/devices/not-a-uuid", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = serveTest(app, http.MethodDelete, "/devices/"+created.ID.String(), "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	_, err = provider.FindOne(context.Background(), created.ID)
+	assert.Equal(t, ErrNotFound, err)
+}
+
+type testUserRoleKey struct {
+	UserID int64 `uri:"user_id" binding:"required"`
+	RoleID int64 `uri:"role_id" binding:"required"`
+}
+
+type testUserRole struct {
+	UserID int64  `json:"user_id" gorm:"primaryKey;autoIncrement:false"`
+	RoleID int64  `json:"role_id" gorm:"primaryKey;autoIncrement:false"`
+	Note   string `json:"note"`
+}
+
+func (testUserRole) NewWithID(id testUserRoleKey) testUserRole {
+	return testUserRole{UserID: id.UserID, RoleID: id.RoleID}
+}
+
+func TestResourceControllerCompositeKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	provider := NewProviderOf[testUserRole, testUserRoleKey](testDB)
+	assert.Equal(t, nil, provider.Migrate())
+	ctx := context.Background()
+	assert.Equal(t, nil, provider.InsertMany(ctx, []*testUserRole{
+		{UserID: 1, RoleID: 1, Note: "one-one"},
+		{UserID: 1, RoleID: 2, Note: "one-two"},
+		{UserID: 2, RoleID: 1, Note: "two-one"},
+	}))
+	app := gin.New()
+	RegisterResourceControllerOf(app.Group("/user-roles"), provider)
+	(&ResourceControllerOf[testUserRole, testUserRoleKey]{
+		Name:         "user-roles",
+		Provider:     provider,
+		Group:        app.Group("/compound/user-roles"),
+		KeySeparator: ",",
+	}).Register()
+
+	w := serveTest(app, http.MethodGet, "/user-roles/1/2", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, true, strings.Contains(w.Body.String(), "one-two"))
+	w = serveTest(app, http.MethodGet, "/compound/user-roles/2,1", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, true, strings.Contains(w.Body.String(), "two-one"))
+	w = serveTest(app, http.MethodGet, "/compound/user-roles/2", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = serveTest(app, http.MethodPut, "/user-roles/1/1", `{"note":"updated"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	v, err := provider.FindOne(ctx, testUserRoleKey{UserID: 1, RoleID: 1})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "updated", v.Note)
+	v, err = provider.FindOne(ctx, testUserRoleKey{UserID: 1, RoleID: 2})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "one-two", v.Note)
+
+	w = serveTest(app, http.MethodDelete, "/compound/user-roles/1,1", "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	results, err := provider.DeleteMany(ctx, []testUserRoleKey{{UserID: 1, RoleID: 1}, {UserID: 2, RoleID: 1}})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []DeleteResult[testUserRoleKey]{
+		{ID: testUserRoleKey{UserID: 1, RoleID: 1}, Error: ErrNotFound.Error()},
+		{ID: testUserRoleKey{UserID: 2, RoleID: 1}, Deleted: true},
+	}, results)
+	cnt, err := provider.Count(ctx, nil)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(1), cnt)
+}
+
+// cancelingRecorder cancels the request once a record is written, as a client going away would.
+type cancelingRecorder struct {
+	*httptest.ResponseRecorder
+	cancel context.CancelFunc
+}
+
+func (w *cancelingRecorder) Write(b []byte) (int, error) {
+	return w.WriteString(string(b))
+}
+
+func (w *cancelingRecorder) WriteString(s string) (int, error) {
+	if strings.Contains(s, `"sensor"`) {
+		w.cancel()
+	}
+	return w.ResponseRecorder.WriteString(s)
+}
+
+func TestResourceControllerStreamLists(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	provider := NewProvider[testReading](testDB)
+	assert.Equal(t, nil, provider.Migrate())
+	assert.Equal(t, nil, provider.InsertMany(context.Background(), []*testReading{
+		{Sensor: "stream", Value: 1},
+		{Sensor: "stream", Value: 2},
+		{Sensor: "stream", Value: 3},
+	}))
+	app := gin.New()
+	(&ResourceController[testReading]{
+		Name:        "readings",
+		Provider:    provider,
+		Group:       app.Group("/readings"),
+		StreamLists: true,
+	}).Register()
+	filter := `filter={"sensor":"stream"}`
+
+	w := serveTest(app, http.MethodGet, `/readings?`+filter+`&range=[0,1]&sort=["value","DESC"]`, "")
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, "items 0-1/3", w.Header().Get("Content-Range"))
+	var records []map[string]any
+	assert.Equal(t, nil, json.Unmarshal(w.Body.Bytes(), &records))
+	assert.Equal(t, 2, len(records))
+	assert.Equal(t, "stream", records[0]["sensor"])
+	_, ok := records[0]["value"]
+	assert.Equal(t, false, ok)
+
+	w = serveTest(app, http.MethodGet, `/readings?filter={"sensor":"none"}`, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "[]", w.Body.String())
+
+	// the client going away stops the stream after the record being written
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequestWithContext(ctx, http.MethodGet, "/readings?"+filter, nil)
+	w = httptest.NewRecorder()
+	app.ServeHTTP(&cancelingRecorder{ResponseRecorder: w, cancel: cancel}, req)
+	assert.Equal(t, 1, strings.Count(w.Body.String(), `"sensor"`))
+	assert.Equal(t, false, strings.HasSuffix(w.Body.String(), "]"))
+}
+
+func TestResourceControllerNegotiation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	provider := NewProvider[testOrder](testDB)
+	assert.Equal(t, nil, provider.Migrate())
+	o := &testOrder{Title: "negotiated"}
+	assert.Equal(t, nil, provider.Insert(context.Background(), o))
+	app := gin.New()
+	RegisterResourceController(app.Group("/orders"), provider)
+	serve := func(method, target, contentType, accept, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		return w
+	}
+	target := "/orders/" + strconv.FormatInt(o.ID, 10)
+
+	w := serve(http.MethodGet, target, "", "application/yaml", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, true, strings.Contains(w.Body.String(), "title: negotiated\n"))
+	w = serve(http.MethodGet, target, "", "text/html", "")
+	assert.Equal(t, http.StatusNotAcceptable, w.Code)
+	w = serve(http.MethodPut, target, "application/yaml", "*/*", "title: renamed\n")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, true, strings.Contains(w.Body.String(), `"title":"renamed"`))
+	w = serve(http.MethodPost, "/orders", "text/plain", "", "title")
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+
+	// records with hidden fields are maps, which encoding/xml cannot encode by itself
+	readings := NewProvider[testReading](testDB)
+	assert.Equal(t, nil, readings.Migrate())
+	r := &testReading{Sensor: "xml", Value: 1}
+	assert.Equal(t, nil, readings.InsertMany(context.Background(), []*testReading{r, {Sensor: "xml", Value: 2}}))
+	RegisterResourceController(app.Group("/readings"), readings)
+	w = serve(http.MethodGet, `/readings?filter={"sensor":"xml"}`, "", "application/xml", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 2, strings.Count(w.Body.String(), "<item><created_at>"))
+	assert.Equal(t, true, strings.HasPrefix(w.Body.String(), "<list><item>"))
+	assert.Equal(t, false, strings.Contains(w.Body.String(), "<value>"))
+	w = serve(http.MethodGet, "/readings/"+strconv.FormatInt(r.ID, 10), "", "application/xml", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, true, strings.Contains(w.Body.String(), "<sensor>xml</sensor>"))
+	assert.Equal(t, true, strings.HasPrefix(w.Body.String(), "<object>"))
+}
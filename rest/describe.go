@@ -0,0 +1,92 @@
+package rest
+
+import (
+	"net/http"
+	"path"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ospiper/ginx"
+)
+
+// resource names the resource in generated documents: Name, or the last segment of the group.
+func (r *ResourceControllerOf[T, ID]) resource() string {
+	if r.Name != "" && r.Name != "resource" {
+		return r.Name
+	}
+	return path.Base(r.Group.BasePath())
+}
+
+// handle registers h on group, documented as the operation verb on the resource, returning T
+// unless op says otherwise. Unless op has content types of its own, requests accepting none of
+// ginx.ResponseFormats are answered with 406 before h runs.
+func (r *ResourceControllerOf[T, ID]) handle(group *gin.RouterGroup, method, relativePath string, h gin.HandlerFunc, verb string, op ginx.Operation) {
+	op.Name = verb + exportedName(r.resource())
+	op.Summary = strings.TrimSpace(verb + " " + r.resource())
+	op.Tags = []string{r.resource()}
+	if op.Response == nil && op.Status != http.StatusNoContent {
+		op.Response = reflect.TypeFor[T]()
+	}
+	if len(op.ContentTypes) == 0 {
+		h = negotiated(h)
+	}
+	ginx.Handle(group, method, relativePath, h, op)
+}
+
+// negotiated answers 406 to the requests h cannot answer in an acceptable format.
+func negotiated(h gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := ginx.CheckAccept(c); err != nil {
+			writer(c).Error(c, err)
+			return
+		}
+		h(c)
+	}
+}
+
+// keyURI is the struct the key segments named param are bound from, nil for a joined composite key.
+func keyURI[ID comparable](separator, param string) reflect.Type {
+	if !isKeyStruct[ID]() {
+		return reflect.StructOf([]reflect.StructField{{
+			Name: exportedName(param),
+			Type: reflect.TypeFor[ID](),
+			Tag:  reflect.StructTag(`uri:"` + param + `"`),
+		}})
+	}
+	if separator != "" {
+		return nil
+	}
+	return reflect.TypeFor[ID]()
+}
+
+// joinURI merges the fields of the uri structs ts into one.
+func joinURI(ts ...reflect.Type) reflect.Type {
+	var fields []reflect.StructField
+	for _, t := range ts {
+		if t == nil {
+			continue
+		}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			f.Index, f.Offset = nil, 0
+			fields = append(fields, f)
+		}
+	}
+	return reflect.StructOf(fields)
+}
+
+// exportedName turns a route segment like user-roles into UserRoles.
+func exportedName(s string) string {
+	var b strings.Builder
+	for _, part := range strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		rs := []rune(part)
+		b.WriteRune(unicode.ToUpper(rs[0]))
+		b.WriteString(string(rs[1:]))
+	}
+	return b.String()
+}
@@ -0,0 +1,14 @@
+package rest
+
+import (
+	"net/http"
+
+	"github.com/ospiper/ginx"
+)
+
+var ErrForbidden error = ginx.NewError(http.StatusForbidden, "forbidden", "forbidden")
+
+// WithStatus wraps err so that controllers answer with code.
+func WithStatus(code int, err error) error {
+	return ginx.WrapError(code, "", err)
+}
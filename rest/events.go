@@ -0,0 +1,151 @@
+package rest
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+
+	"github.com/ospiper/ginx"
+	"github.com/ospiper/ginx/dbx"
+	"github.com/ospiper/ginx/util"
+)
+
+// eventsHeartbeat is the interval of the comments keeping idle event streams open through proxies.
+const eventsHeartbeat = 30 * time.Second
+
+// EventsQuery selects the records whose changes are streamed, like the filter of SimpleRestQuery.
+type EventsQuery struct {
+	Filter string `form:"filter"`
+}
+
+// ChangeMessage is the data of the server-sent events of a change feed.
+type ChangeMessage[ID comparable] struct {
+	ID      ID  `json:"id"`
+	Payload any `json:"payload"`
+}
+
+// events streams the changes published to Events as server-sent events named after their type.
+// Only the records matching the filter and the policy scope of the caller, and which it may read,
+// are sent, without the fields it may not read. A client reconnecting with a Last-Event-ID header
+// first gets the kept events it missed.
+func (r *ResourceControllerOf[T, ID]) events(c *gin.Context) {
+	ctx := r.scope(ginx.RequestContext(c))
+	if r.Policy != nil && !r.Policy.CanList(ctx) {
+		writer(c).Error(c, ErrForbidden)
+		return
+	}
+	cond, err := BuildSimpleRestConditions(c)
+	if err != nil {
+		writer(c).Error(c, ginx.BindError(err))
+		return
+	}
+	if h, ok := r.Hooks.(BeforeListHook); ok {
+		err = h.BeforeList(ctx, cond)
+		if err != nil {
+			writer(c).Error(c, beforeHookError(err))
+			return
+		}
+	}
+	sch, err := schemaOf[T](r.Provider.GetDB())
+	if err != nil {
+		writer(c).Error(c, err)
+		return
+	}
+	scope, err := matcherOf(sch, ScopeFromContext(ctx))
+	if err != nil {
+		writer(c).Error(c, fmt.Errorf("events: policy scope: %w", err))
+		return
+	}
+	filter, err := matcherOf(sch, cond.Filters)
+	if err != nil {
+		writer(c).Error(c, ginx.NewError(http.StatusBadRequest, "unsupported_filter", err.Error()))
+		return
+	}
+	var after uint64
+	id := c.GetHeader("Last-Event-ID")
+	if id != "" {
+		after, err = strconv.ParseUint(id, 10, 64)
+		if err != nil {
+			writer(c).Error(c, ginx.BindError(fmt.Errorf("Last-Event-ID: %w", err)))
+			return
+		}
+	}
+	replay, events, cancel := r.Events.Subscribe(after, id != "")
+	defer cancel()
+
+	tenant, tenanted := dbx.TenantFromContext(ctx)
+	guard := r.guard()
+	visible := func(ev ChangeEvent[T, ID]) bool {
+		if ev.Record == nil {
+			return false
+		}
+		if t, ok := util.As[dbx.WithTenant](ev.Record); ok && tenanted && t.GetTenantID() != tenant {
+			return false
+		}
+		rv := reflect.ValueOf(ev.Record).Elem()
+		if !scope(ctx, rv) || !filter(ctx, rv) {
+			return false
+		}
+		return r.Policy == nil || r.Policy.CanRead(ctx, ev.Record)
+	}
+	send := func(ev ChangeEvent[T, ID]) error {
+		if !visible(ev) {
+			return nil
+		}
+		payload, err := guard.view(ctx, ev.Record)
+		if err != nil {
+			return err
+		}
+		c.Render(-1, sse.Event{
+			Id:    strconv.FormatUint(ev.Seq, 10),
+			Event: ev.Type,
+			Data:  ChangeMessage[ID]{ID: ev.Key, Payload: payload},
+		})
+		c.Writer.Flush()
+		return nil
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Header("Content-Type", "text/event-stream")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+	err = func() error {
+		for _, ev := range replay {
+			if err := send(ev); err != nil {
+				return err
+			}
+		}
+		heartbeat := time.NewTicker(eventsHeartbeat)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case <-c.Request.Context().Done():
+				return nil
+			case <-heartbeat.C:
+				if _, err := c.Writer.WriteString(": keep-alive\n\n"); err != nil {
+					return err
+				}
+				c.Writer.Flush()
+			case ev, ok := <-events:
+				if !ok {
+					// dropped for lagging behind, the client resumes from its last event
+					return nil
+				}
+				if err := send(ev); err != nil {
+					return err
+				}
+			}
+		}
+	}()
+	if err != nil {
+		_ = c.Error(err)
+		c.Abort()
+	}
+}
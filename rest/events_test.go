@@ -0,0 +1,184 @@
+package rest
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/assert/v2"
+
+	"github.com/ospiper/ginx/dbx"
+)
+
+func TestBroker(t *testing.T) {
+	b := NewBroker[testReading](2)
+	replay, events, cancel := b.Subscribe(0, true)
+	assert.Equal(t, 0, len(replay))
+	for i := 1; i <= 3; i++ {
+		b.Publish(EventCreated, int64(i), &testReading{Value: i})
+	}
+	ev := <-events
+	assert.Equal(t, uint64(1), ev.Seq)
+	assert.Equal(t, int64(1), ev.Key)
+
+	replay, _, stop := b.Subscribe(1, true)
+	stop()
+	assert.Equal(t, 2, len(replay))
+	assert.Equal(t, uint64(2), replay[0].Seq)
+	assert.Equal(t, uint64(3), replay[1].Seq)
+	replay, _, stop = b.Subscribe(7, true)
+	stop()
+	assert.Equal(t, 0, len(replay))
+
+	// a subscriber lagging behind is dropped
+	for i := 0; i < subscriberBuffer; i++ {
+		b.Publish(EventUpdated, 1, &testReading{})
+	}
+	n := 0
+	for range events {
+		n++
+	}
+	assert.Equal(t, subscriberBuffer, n)
+	cancel()
+}
+
+func TestMatcher(t *testing.T) {
+	sch, err := schemaOf[testReading](testDB)
+	assert.Equal(t, nil, err)
+	v := reflect.ValueOf(&testReading{Sensor: "Hall-1", Value: 5}).Elem()
+	for filter, want := range map[string]bool{
+		`{"sensor":"Hall-1"}`:                true,
+		`{"sensor_neq":"Hall-1"}`:            false,
+		`{"sensor_eq_any":["a","Hall-1"]}`:   true,
+		`{"sensor_like":"hall"}`:             false,
+		`{"sensor_ilike":"hall"}`:            true,
+		`{"sensor_regex":"^h.*1$"}`:          true,
+		`{"value_between":[1,5]}`:            true,
+		`{"value_between":[6,9]}`:            false,
+		`{"deleted_at_is_null":true}`:        true,
+		`{"value":5,"sensor_inc_any":["x"]}`: false,
+	} {
+		fs, err := buildFilters(filter)
+		assert.Equal(t, nil, err)
+		m, err := matcherOf(sch, fs)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, want, m(context.Background(), v))
+	}
+	_, err = matcherOf(sch, []FilterFunc{Q("sensor", "hall")})
+	assert.NotEqual(t, nil, err)
+	_, err = matcherOf(sch, []FilterFunc{Eq("missing", 1)})
+	assert.NotEqual(t, nil, err)
+}
+
+type testSSE struct {
+	id, event, data string
+}
+
+func readSSE(t *testing.T, r *bufio.Reader) testSSE {
+	var ev testSSE
+	for {
+		line, err := r.ReadString('\n')
+		assert.Equal(t, nil, err)
+		line = strings.TrimRight(line, "\n")
+		if line == "" {
+			return ev
+		}
+		k, v, _ := strings.Cut(line, ":")
+		v = strings.TrimPrefix(v, " ")
+		switch k {
+		case "id":
+			ev.id = v
+		case "event":
+			ev.event = v
+		case "data":
+			ev.data = v
+		}
+	}
+}
+
+func TestResourceControllerEvents(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	broker := NewBroker[testReading](16)
+	provider := NewPublishingProvider(NewProvider[testReading](testDB), broker)
+	assert.Equal(t, nil, provider.Migrate())
+	app := gin.New()
+	app.Use(func(c *gin.Context) {
+		c.Set(RoleContextKey, c.GetHeader("X-Role"))
+	})
+	ctrl := &ResourceController[testReading]{Provider: provider, Group: app.Group("/readings"), Events: broker}
+	ctrl.Register()
+	srv := httptest.NewServer(app)
+	defer srv.Close()
+
+	subscribe := func(role, lastID string) (*bufio.Reader, func()) {
+		ctx, cancel := context.WithCancel(context.Background())
+		q := url.Values{"filter": {`{"sensor_like":"events"}`}}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/readings/events?"+q.Encode(), nil)
+		assert.Equal(t, nil, err)
+		req.Header.Set("X-Role", role)
+		if lastID != "" {
+			req.Header.Set("Last-Event-ID", lastID)
+		}
+		res, err := http.DefaultClient.Do(req)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+		return bufio.NewReader(res.Body), func() {
+			cancel()
+			_ = res.Body.Close()
+		}
+	}
+	admin, stopAdmin := subscribe("admin", "")
+	defer stopAdmin()
+	user, stopUser := subscribe("", "")
+	defer stopUser()
+
+	ctx := context.Background()
+	v := &testReading{Sensor: "events-a", Value: 4}
+	assert.Equal(t, nil, provider.Insert(ctx, v))
+	assert.Equal(t, nil, provider.Insert(ctx, &testReading{Sensor: "other"}))
+	errRollback := errors.New("rollback")
+	err := dbx.Transaction(ctx, testDB, func(ctx context.Context) error {
+		assert.Equal(t, nil, provider.Insert(ctx, &testReading{Sensor: "events-rolled-back"}))
+		return errRollback
+	})
+	assert.Equal(t, errRollback, err)
+	_, err = provider.UpdateFields(ctx, v.ID, map[string]any{"value": 6})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, nil, provider.Delete(ctx, v.ID))
+
+	ev := readSSE(t, admin)
+	assert.Equal(t, "1", ev.id)
+	assert.Equal(t, "created", ev.event)
+	assert.Equal(t, true, strings.Contains(ev.data, `"sensor":"events-a","value":4}`))
+	ev = readSSE(t, admin)
+	assert.Equal(t, "3", ev.id)
+	assert.Equal(t, "updated", ev.event)
+	assert.Equal(t, true, strings.Contains(ev.data, `"value":6`))
+	ev = readSSE(t, admin)
+	assert.Equal(t, "4", ev.id)
+	assert.Equal(t, "deleted", ev.event)
+
+	// fields the caller may not read are left out
+	ev = readSSE(t, user)
+	assert.Equal(t, "created", ev.event)
+	assert.Equal(t, true, strings.HasPrefix(ev.data, `{"id":`))
+	assert.Equal(t, false, strings.Contains(ev.data, `"value"`))
+
+	resumed, stopResumed := subscribe("admin", "1")
+	defer stopResumed()
+	ev = readSSE(t, resumed)
+	assert.Equal(t, "3", ev.id)
+	ev = readSSE(t, resumed)
+	assert.Equal(t, "4", ev.id)
+
+	w := serveTest(app, http.MethodGet, `/readings/events?filter={"sensor_q":"x"}`, "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,170 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ospiper/ginx"
+)
+
+// exportBatchSize is the number of records read from the database per round trip when exporting.
+const exportBatchSize = 500
+
+// ExportQuery selects the records to export like SimpleRestQuery, ignoring its range.
+type ExportQuery struct {
+	SimpleRestQuery
+	// Format is csv or ndjson, csv when empty.
+	Format string `form:"format" binding:"omitempty,oneof=csv ndjson"`
+}
+
+var exportTypes = map[string]string{
+	"csv":    "text/csv; charset=utf-8",
+	"ndjson": "application/x-ndjson",
+}
+
+// export streams every record matching the simple-rest query as CSV, one column per JSON field
+// of T, or as newline-delimited JSON. Records are read in batches and flushed as they go.
+func (r *ResourceControllerOf[T, ID]) export(c *gin.Context) {
+	ctx := r.scope(ginx.RequestContext(c))
+	if r.Policy != nil && !r.Policy.CanList(ctx) {
+		writer(c).Error(c, ErrForbidden)
+		return
+	}
+	req := new(ExportQuery)
+	if err := c.ShouldBindQuery(req); err != nil {
+		writer(c).Error(c, ginx.BindError(err))
+		return
+	}
+	cond, err := BuildSimpleRestConditions(c)
+	if err != nil {
+		writer(c).Error(c, ginx.BindError(err))
+		return
+	}
+	cond.Pagination = nil
+	if h, ok := r.Hooks.(BeforeListHook); ok {
+		err = h.BeforeList(ctx, cond)
+		if err != nil {
+			writer(c).Error(c, beforeHookError(err))
+			return
+		}
+	}
+	format := req.Format
+	if format == "" {
+		format = "csv"
+	}
+	hidden := r.guard().hidden(ctx)
+	var columns []string
+	for _, f := range fieldAccessOf(reflect.TypeFor[T]()) {
+		if !slices.Contains(hidden, f.name) {
+			columns = append(columns, f.name)
+		}
+	}
+
+	var cw *csv.Writer
+	started := false
+	start := func() error {
+		started = true
+		c.Header("Content-Type", exportTypes[format])
+		c.Header("Content-Disposition", `attachment; filename="`+r.resource()+"."+format+`"`)
+		c.Status(http.StatusOK)
+		if format != "csv" {
+			return nil
+		}
+		cw = csv.NewWriter(c.Writer)
+		return cw.Write(columns)
+	}
+	err = r.Provider.FindInBatches(ctx, cond, exportBatchSize, func(vs []*T) error {
+		if !started {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		for _, v := range vs {
+			var err error
+			if cw != nil {
+				err = writeCSVRecord(cw, v, hidden, columns)
+			} else {
+				err = writeNDJSONRecord(c.Writer, v, hidden)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if cw != nil {
+			cw.Flush()
+			if err := cw.Error(); err != nil {
+				return err
+			}
+		}
+		c.Writer.Flush()
+		return nil
+	})
+	if err == nil && !started {
+		err = start()
+		if cw != nil {
+			cw.Flush()
+		}
+	}
+	if err != nil {
+		if !started {
+			writer(c).Error(c, err)
+			return
+		}
+		// the status is sent already, the client sees a truncated body
+		_ = c.Error(err)
+		c.Abort()
+	}
+}
+
+func writeCSVRecord(w *csv.Writer, v any, hidden, columns []string) error {
+	m, err := strip(v, hidden)
+	if err != nil {
+		return err
+	}
+	row := make([]string, len(columns))
+	for i, name := range columns {
+		row[i] = csvCell(m[name])
+	}
+	return w.Write(row)
+}
+
+// csvCell writes strings as is, null and missing values as empty cells and anything else as JSON.
+func csvCell(raw json.RawMessage) string {
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return ""
+	}
+	if raw[0] == '"' {
+		var s string
+		if json.Unmarshal(raw, &s) == nil {
+			return s
+		}
+	}
+	return string(raw)
+}
+
+func writeNDJSONRecord(w http.ResponseWriter, v any, hidden []string) error {
+	b, err := encodeVisible(v, hidden)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+// encodeVisible encodes v as JSON without the hidden fields.
+func encodeVisible(v any, hidden []string) ([]byte, error) {
+	if len(hidden) == 0 {
+		return json.Marshal(v)
+	}
+	m, err := strip(v, hidden)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(m)
+}
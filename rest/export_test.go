@@ -0,0 +1,67 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/assert/v2"
+)
+
+func TestResourceControllerExport(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	provider := NewProvider[testReading](testDB)
+	assert.Equal(t, nil, provider.Migrate())
+	ctx := context.Background()
+	assert.Equal(t, nil, provider.InsertMany(ctx, []*testReading{
+		{Sensor: "export,a", Value: 3},
+		{Sensor: "export-b", Value: 7},
+		{Sensor: "other", Value: 1},
+	}))
+	app := gin.New()
+	app.Use(func(c *gin.Context) {
+		c.Set(RoleContextKey, c.GetHeader("X-Role"))
+	})
+	(&ResourceController[testReading]{
+		Name:     "readings",
+		Provider: provider,
+		Group:    app.Group("/readings"),
+		Export:   true,
+	}).Register()
+	// controllers export nothing unless asked to, export is taken for a malformed :id
+	RegisterResourceController(app.Group("/hidden-readings"), provider)
+	hidden := serveTest(app, http.MethodGet, "/hidden-readings/export", "")
+	assert.Equal(t, http.StatusBadRequest, hidden.Code)
+	filter := `filter={"sensor_like":"export"}`
+
+	req := httptest.NewRequest(http.MethodGet, `/readings/export?`+filter+`&sort=["value","DESC"]`, nil)
+	req.Header.Set("X-Role", "admin")
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="readings.csv"`, w.Header().Get("Content-Disposition"))
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	assert.Equal(t, 3, len(lines))
+	assert.Equal(t, "id,created_at,updated_at,deleted_at,sensor,value", lines[0])
+	assert.Equal(t, true, strings.HasSuffix(lines[1], ",,export-b,7"))
+	assert.Equal(t, true, strings.HasSuffix(lines[2], `,,"export,a",3`))
+
+	w = serveTest(app, http.MethodGet, `/readings/export?format=ndjson&`+filter, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+	lines = strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	assert.Equal(t, 2, len(lines))
+	assert.Equal(t, true, strings.Contains(lines[0], `"sensor":"export,a"`))
+	assert.Equal(t, false, strings.Contains(lines[0], `"value"`))
+
+	w = serveTest(app, http.MethodGet, `/readings/export?filter={"sensor":"none"}`, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "id,created_at,updated_at,deleted_at,sensor\n", w.Body.String())
+
+	w = serveTest(app, http.MethodGet, "/readings/export?format=xml", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,194 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// RoleContextKey is the key the role of the caller is stored under, usually by an
+// authentication middleware calling gin.Context.Set.
+const RoleContextKey = "ginx.rest.role"
+
+// ContextWithRole returns a copy of ctx carrying role.
+func ContextWithRole(ctx context.Context, role string) context.Context {
+	return context.WithValue(ctx, RoleContextKey, role)
+}
+
+// RoleFromContext returns the role carried by ctx.
+func RoleFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	role, _ := ctx.Value(RoleContextKey).(string)
+	return role
+}
+
+// FieldPolicy decides field visibility instead of access tags when a Policy implements it.
+// Fields are identified by their JSON names.
+type FieldPolicy interface {
+	CanReadField(ctx context.Context, field string) bool
+	CanWriteField(ctx context.Context, field string) bool
+}
+
+// fieldAccess is a field restricted by a tag like `access:"read=admin|hr;write=admin"`.
+// A nil role list allows every role.
+type fieldAccess struct {
+	index []int
+	name  string
+	read  []string
+	write []string
+}
+
+var fieldAccessCache sync.Map // reflect.Type -> []fieldAccess
+
+func fieldAccessOf(t reflect.Type) []fieldAccess {
+	if v, ok := fieldAccessCache.Load(t); ok {
+		return v.([]fieldAccess)
+	}
+	fields := parseFieldAccess(t, nil)
+	fieldAccessCache.Store(t, fields)
+	return fields
+}
+
+func parseFieldAccess(t reflect.Type, index []int) []fieldAccess {
+	var ret []fieldAccess
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		idx := append(slices.Clone(index), i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" || (!f.IsExported() && !f.Anonymous) {
+			continue
+		}
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			ret = append(ret, parseFieldAccess(f.Type, idx)...)
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fa := fieldAccess{index: idx, name: name}
+		for _, rule := range strings.Split(f.Tag.Get("access"), ";") {
+			op, roles, ok := strings.Cut(strings.TrimSpace(rule), "=")
+			if !ok {
+				continue
+			}
+			switch op {
+			case "read":
+				fa.read = strings.Split(roles, "|")
+			case "write":
+				fa.write = strings.Split(roles, "|")
+			}
+		}
+		ret = append(ret, fa)
+	}
+	return ret
+}
+
+// fieldGuard applies field permissions of T for the caller of a request.
+type fieldGuard[T any] struct {
+	policy FieldPolicy
+	fields []fieldAccess
+	reject bool
+}
+
+func newFieldGuard[T any](policy any, reject bool) *fieldGuard[T] {
+	g := &fieldGuard[T]{reject: reject}
+	g.policy, _ = policy.(FieldPolicy)
+	for _, f := range fieldAccessOf(reflect.TypeFor[T]()) {
+		if g.policy != nil || f.read != nil || f.write != nil {
+			g.fields = append(g.fields, f)
+		}
+	}
+	return g
+}
+
+func (g *fieldGuard[T]) canRead(ctx context.Context, f fieldAccess) bool {
+	if g.policy != nil {
+		return g.policy.CanReadField(ctx, f.name)
+	}
+	return f.read == nil || slices.Contains(f.read, RoleFromContext(ctx))
+}
+
+func (g *fieldGuard[T]) canWrite(ctx context.Context, f fieldAccess) bool {
+	if g.policy != nil {
+		return g.policy.CanWriteField(ctx, f.name)
+	}
+	return f.write == nil || slices.Contains(f.write, RoleFromContext(ctx))
+}
+
+// hidden returns the JSON names of the fields the caller may not read.
+func (g *fieldGuard[T]) hidden(ctx context.Context) []string {
+	var ret []string
+	for _, f := range g.fields {
+		if !g.canRead(ctx, f) {
+			ret = append(ret, f.name)
+		}
+	}
+	return ret
+}
+
+// view returns v with unreadable fields removed, or v itself when everything is readable.
+func (g *fieldGuard[T]) view(ctx context.Context, v *T) (any, error) {
+	hidden := g.hidden(ctx)
+	if len(hidden) == 0 || v == nil {
+		return v, nil
+	}
+	return strip(v, hidden)
+}
+
+// viewList is view for every element of vs.
+func (g *fieldGuard[T]) viewList(ctx context.Context, vs []*T) (any, error) {
+	hidden := g.hidden(ctx)
+	if len(hidden) == 0 {
+		return vs, nil
+	}
+	ret := make([]map[string]json.RawMessage, len(vs))
+	for i, v := range vs {
+		m, err := strip(v, hidden)
+		if err != nil {
+			return nil, err
+		}
+		ret[i] = m
+	}
+	return ret, nil
+}
+
+func strip(v any, hidden []string) (map[string]json.RawMessage, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]json.RawMessage
+	if err = json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	for _, name := range hidden {
+		delete(m, name)
+	}
+	return m, nil
+}
+
+// write drops values the caller may not write from v, or rejects them with ErrForbidden
+// if the guard is strict. Unset (zero) fields are never considered written.
+func (g *fieldGuard[T]) write(ctx context.Context, v *T) error {
+	rv := reflect.ValueOf(v).Elem()
+	for _, f := range g.fields {
+		if g.canWrite(ctx, f) {
+			continue
+		}
+		fv, err := rv.FieldByIndexErr(f.index)
+		if err != nil || fv.IsZero() {
+			continue
+		}
+		if g.reject {
+			return fmt.Errorf("%w: field %s is read-only", ErrForbidden, f.name)
+		}
+		fv.SetZero()
+	}
+	return nil
+}
@@ -4,7 +4,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
 	"reflect"
+	"slices"
 	"strings"
 
 	"gorm.io/gorm/clause"
@@ -112,9 +114,10 @@ func buildFilters(fs string) ([]FilterFunc, error) {
 		fmt.Println(err)
 		return nil, nil
 	}
+	// in a stable order, so that the same filter renders the same SQL, e.g. to cache it
 	var ret []FilterFunc
-	for k, v := range f {
-		_, expr := parseFilter(k, v)
+	for _, k := range slices.Sorted(maps.Keys(f)) {
+		_, expr := parseFilter(k, f[k])
 		ret = append(ret, expr)
 	}
 	return ret, nil
@@ -0,0 +1,42 @@
+package rest
+
+import (
+	"context"
+)
+
+// Lifecycle hooks a ResourceController invokes around its generated routes when its Hooks
+// value implements them. Unlike gorm model hooks they receive the request context, made by
+// ginx.RequestContext: the request itself, e.g. its headers, is reachable through
+// ginx.RequestFromContext and the values set on the *gin.Context through ctx.Value.
+//
+// Before* hooks reject the request with 400 unless the error carries a status (see WithStatus),
+// After* hooks fail it with 500. Mutations and their hooks share one transaction,
+// so a failing After* hook rolls the change back.
+
+type BeforeListHook interface {
+	BeforeList(ctx context.Context, conditions *FindConditions) error
+}
+
+type BeforeCreateHook[T any] interface {
+	BeforeCreate(ctx context.Context, v *T) error
+}
+
+type AfterCreateHook[T any] interface {
+	AfterCreate(ctx context.Context, v *T) error
+}
+
+type BeforeUpdateHook[T any] interface {
+	BeforeUpdate(ctx context.Context, old, new *T) error
+}
+
+type AfterUpdateHook[T any] interface {
+	AfterUpdate(ctx context.Context, old, new *T) error
+}
+
+type BeforeDeleteHook[T any] interface {
+	BeforeDelete(ctx context.Context, v *T) error
+}
+
+type AfterDeleteHook[T any] interface {
+	AfterDelete(ctx context.Context, v *T) error
+}
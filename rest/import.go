@@ -0,0 +1,358 @@
+package rest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+
+	"github.com/ospiper/ginx"
+	"github.com/ospiper/ginx/dbx"
+	"github.com/ospiper/ginx/util"
+)
+
+// ImportQuery controls how POST /<resource>/import loads its rows.
+type ImportQuery struct {
+	// DryRun reports what the import would do and rolls everything back.
+	DryRun bool `form:"dry_run"`
+	// OnConflict handles rows whose primary key or unique keys are taken: skip them,
+	// update the record sharing the primary key or fail the row, the default.
+	OnConflict string `form:"on_conflict" binding:"omitempty,oneof=skip update fail"`
+	// BatchSize is the number of rows committed per transaction.
+	BatchSize int `form:"batch_size" binding:"omitempty,min=1,max=1000"`
+}
+
+// Import row statuses.
+const (
+	ImportInserted = "inserted"
+	ImportUpdated  = "updated"
+	ImportSkipped  = "skipped"
+	ImportFailed   = "failed"
+)
+
+// ImportRow is the outcome of one row of an import, numbered from 1 in the order of the body,
+// not counting the header of CSV bodies.
+type ImportRow struct {
+	Row    int               `json:"row"`
+	Status string            `json:"status"`
+	Code   string            `json:"code,omitempty"`
+	Error  string            `json:"error,omitempty"`
+	Fields []ginx.FieldError `json:"fields,omitempty"`
+}
+
+// ImportReport sums up an import.
+type ImportReport struct {
+	DryRun   bool        `json:"dry_run"`
+	Inserted int         `json:"inserted"`
+	Updated  int         `json:"updated"`
+	Skipped  int         `json:"skipped"`
+	Failed   int         `json:"failed"`
+	Rows     []ImportRow `json:"rows"`
+}
+
+func (rep *ImportReport) add(row ImportRow) {
+	switch row.Status {
+	case ImportInserted:
+		rep.Inserted++
+	case ImportUpdated:
+		rep.Updated++
+	case ImportSkipped:
+		rep.Skipped++
+	case ImportFailed:
+		rep.Failed++
+	}
+	rep.Rows = append(rep.Rows, row)
+}
+
+var (
+	errImportSkipped = errors.New("import: row skipped")
+	errImportDryRun  = errors.New("import: dry run")
+)
+
+// importRequest is an import row waiting for its batch, err is set if it could not be read.
+type importRequest struct {
+	row int
+	raw json.RawMessage
+	err error
+}
+
+// importRows reads the body as CSV, NDJSON or a JSON array of objects depending on its
+// content type, refusing the others. CSV bodies start with a header row of JSON field names
+// of T, the error is set if it does not. Rows which cannot be parsed are yielded with an error, reading stops
+// at errors it cannot recover from.
+func importRows[T any](c *gin.Context) (iter.Seq2[json.RawMessage, error], error) {
+	switch c.ContentType() {
+	case "text/csv":
+		return csvRows[T](c.Request.Body)
+	case "application/x-ndjson":
+		return ndjsonRows(c.Request.Body), nil
+	case binding.MIMEJSON, "":
+		return jsonRows(c.Request.Body), nil
+	default:
+		return nil, ginx.ErrUnsupportedMediaType
+	}
+}
+
+func jsonRows(r io.Reader) iter.Seq2[json.RawMessage, error] {
+	return func(yield func(json.RawMessage, error) bool) {
+		dec := json.NewDecoder(r)
+		tok, err := dec.Token()
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		if tok != json.Delim('[') {
+			yield(nil, errors.New("import: expect a JSON array of objects"))
+			return
+		}
+		for dec.More() {
+			var raw json.RawMessage
+			if err := dec.Decode(&raw); err != nil {
+				yield(nil, err)
+				return
+			}
+			if !yield(raw, nil) {
+				return
+			}
+		}
+	}
+}
+
+func ndjsonRows(r io.Reader) iter.Seq2[json.RawMessage, error] {
+	return func(yield func(json.RawMessage, error) bool) {
+		sc := bufio.NewScanner(r)
+		sc.Buffer(nil, 1<<20)
+		for sc.Scan() {
+			line := strings.TrimSpace(sc.Text())
+			if line == "" {
+				continue
+			}
+			var err error
+			if !json.Valid([]byte(line)) {
+				err = errors.New("import: invalid JSON")
+			}
+			if !yield(json.RawMessage(line), err) {
+				return
+			}
+		}
+		if err := sc.Err(); err != nil {
+			yield(nil, err)
+		}
+	}
+}
+
+func csvRows[T any](r io.Reader) (iter.Seq2[json.RawMessage, error], error) {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("import: read header: %w", err)
+	}
+	types := make(map[string]reflect.Type)
+	t := reflect.TypeFor[T]()
+	for _, f := range fieldAccessOf(t) {
+		types[f.name] = t.FieldByIndex(f.index).Type
+	}
+	for _, name := range header {
+		if _, ok := types[name]; !ok {
+			return nil, fmt.Errorf("import: unknown column %s", name)
+		}
+	}
+	return func(yield func(json.RawMessage, error) bool) {
+		for {
+			record, err := cr.Read()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				var perr *csv.ParseError
+				if !yield(nil, err) || !errors.As(err, &perr) {
+					return
+				}
+				continue
+			}
+			m := make(map[string]json.RawMessage, len(header))
+			for i, name := range header {
+				if record[i] != "" {
+					m[name] = csvValue(types[name], record[i])
+				}
+			}
+			raw, err := json.Marshal(m)
+			if !yield(raw, err) {
+				return
+			}
+		}
+	}, nil
+}
+
+// csvValue turns a CSV cell into the JSON value of a field of type t, the reverse of csvCell.
+func csvValue(t reflect.Type, cell string) json.RawMessage {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	text := t.Kind() == reflect.String || t == reflect.TypeFor[time.Time]() ||
+		reflect.PointerTo(t).Implements(reflect.TypeFor[encoding.TextUnmarshaler]())
+	if !text && json.Valid([]byte(cell)) {
+		return json.RawMessage(cell)
+	}
+	b, _ := json.Marshal(cell)
+	return b
+}
+
+// importBody loads the rows of the request body, batch by batch, see ImportQuery.
+func (r *ResourceControllerOf[T, ID]) importBody(c *gin.Context) {
+	q := new(ImportQuery)
+	if err := c.ShouldBindQuery(q); err != nil {
+		writer(c).Error(c, ginx.BindError(err))
+		return
+	}
+	if q.BatchSize == 0 {
+		q.BatchSize = defaultBatchSize
+	}
+	rows, err := importRows[T](c)
+	if err != nil {
+		writer(c).Error(c, ginx.BindError(err))
+		return
+	}
+	ctx := r.scope(ginx.RequestContext(c))
+	report := &ImportReport{DryRun: q.DryRun, Rows: make([]ImportRow, 0)}
+	batch := make([]importRequest, 0, q.BatchSize)
+	flush := func() error {
+		out, err := r.importBatch(ctx, c, batch, q)
+		if err != nil {
+			return err
+		}
+		for _, row := range out {
+			report.add(row)
+		}
+		batch = batch[:0]
+		return nil
+	}
+	n := 0
+	for raw, err := range rows {
+		n++
+		batch = append(batch, importRequest{row: n, raw: raw, err: err})
+		if len(batch) == q.BatchSize {
+			if err := flush(); err != nil {
+				writer(c).Error(c, err)
+				return
+			}
+		}
+	}
+	if len(batch) > 0 {
+		if err := flush(); err != nil {
+			writer(c).Error(c, err)
+			return
+		}
+	}
+	writer(c).Success(c, http.StatusOK, report)
+}
+
+// importBatch loads batch in a transaction, each row in a savepoint so that a failing row
+// leaves the others in. The error is only set when the whole batch fails.
+func (r *ResourceControllerOf[T, ID]) importBatch(ctx context.Context, c *gin.Context, batch []importRequest, q *ImportQuery) ([]ImportRow, error) {
+	rows := make([]ImportRow, len(batch))
+	err := dbx.Transaction(ctx, r.Provider.GetDB(), func(ctx context.Context) error {
+		for i, req := range batch {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			rows[i] = ImportRow{Row: req.row}
+			err := req.err
+			if err == nil {
+				rows[i].Status, err = r.importRow(ctx, c, req.raw, q.OnConflict)
+			} else {
+				err = ginx.BindError(err)
+			}
+			if err != nil {
+				e := ginx.MapError(err)
+				rows[i].Status, rows[i].Code, rows[i].Error, rows[i].Fields = ImportFailed, e.Code, e.Error(), e.Fields
+			}
+		}
+		if q.DryRun {
+			return errImportDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errImportDryRun) {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// importRow binds and stores one row, returning its status. Rows are bound like the body of a
+// POST, or of a PUT when they update a record. Their key is only read to find conflicts with
+// on_conflict=skip or update; otherwise it is left to the server like in a POST.
+func (r *ResourceControllerOf[T, ID]) importRow(ctx context.Context, c *gin.Context, raw json.RawMessage, onConflict string) (string, error) {
+	var id ID
+	if onConflict == "skip" || onConflict == "update" {
+		var keyed T
+		if err := json.Unmarshal(raw, &keyed); err != nil {
+			return "", ginx.BindError(err)
+		}
+		if k, ok := util.As[dbx.WithIDOf[ID]](&keyed); ok {
+			id = k.GetID()
+		}
+	}
+	status := ImportInserted
+	err := dbx.Transaction(ctx, r.Provider.GetDB(), func(ctx context.Context) error {
+		var zero ID
+		if id != zero {
+			old, err := r.Provider.FindOne(ctx, id)
+			switch {
+			case errors.Is(err, ErrNotFound):
+			case err != nil:
+				return err
+			case onConflict == "skip":
+				return errImportSkipped
+			default:
+				data := new(T)
+				if err := importInput(c, r.UpdateInput, raw, data); err != nil {
+					return ginx.BindError(err)
+				}
+				status = ImportUpdated
+				return r.modify(ctx, id, old, data, nil)
+			}
+		}
+		data := new(T)
+		if err := importInput(c, r.CreateInput, raw, data); err != nil {
+			return ginx.BindError(err)
+		}
+		err := r.insert(ctx, data, nil)
+		if onConflict == "skip" && isDuplicate(err) {
+			return errImportSkipped
+		}
+		return err
+	})
+	if errors.Is(err, errImportSkipped) {
+		return ImportSkipped, nil
+	}
+	return status, err
+}
+
+// importInput binds raw into dst with binder as if it were the JSON body of c.
+func importInput[T any](c *gin.Context, binder Binder[T], raw json.RawMessage, dst *T) error {
+	rc := c.Copy()
+	rc.Request = c.Request.Clone(c.Request.Context())
+	rc.Request.Body = io.NopCloser(bytes.NewReader(raw))
+	rc.Request.ContentLength = int64(len(raw))
+	rc.Request.Header.Set("Content-Type", binding.MIMEJSON)
+	return bind(rc, binder, dst)
+}
+
+func isDuplicate(err error) bool {
+	e, ok := ginx.AsError(err)
+	return ok && e.Code == "duplicate"
+}
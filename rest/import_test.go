@@ -0,0 +1,129 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/assert/v2"
+
+	"github.com/ospiper/ginx/dbx"
+)
+
+type testContact struct {
+	dbx.Model
+	Email string `json:"email" binding:"required,email" gorm:"uniqueIndex"`
+	Name  string `json:"name"`
+	Age   int    `json:"age"`
+}
+
+func (testContact) NewWithID(id int64) testContact {
+	return testContact{Model: dbx.Model{ID: id}}
+}
+
+type testContactInput struct {
+	Email string `json:"email" binding:"required,email"`
+	Name  string `json:"name"`
+}
+
+func importTest(h http.Handler, query, contentType, body string) (*httptest.ResponseRecorder, *ImportReport) {
+	req := httptest.NewRequest(http.MethodPost, "/contacts/import"+query, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	report := new(ImportReport)
+	_ = json.Unmarshal(w.Body.Bytes(), report)
+	return w, report
+}
+
+func TestResourceControllerImport(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	provider := NewProvider[testContact](testDB)
+	assert.Equal(t, nil, provider.Migrate())
+	ctx := context.Background()
+	app := gin.New()
+	(&ResourceController[testContact]{
+		Name:     "contacts",
+		Provider: provider,
+		Group:    app.Group("/contacts"),
+		Import:   true,
+	}).Register()
+	// controllers import nothing unless asked to
+	RegisterResourceController(app.Group("/hidden-contacts"), provider)
+	hidden := serveTest(app, http.MethodPost, "/hidden-contacts/import", `[{"email":"eve@example.com"}]`)
+	assert.Equal(t, http.StatusNotFound, hidden.Code)
+	count := func() int64 {
+		cnt, err := provider.Count(ctx, nil)
+		assert.Equal(t, nil, err)
+		return cnt
+	}
+
+	w, report := importTest(app, "?batch_size=2", "application/json",
+		`[{"email":"ann@example.com","name":"ann"},{"email":"nope"},{"email":"bob@example.com","age":"old"},{"email":"cid@example.com"}]`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 2, report.Inserted)
+	assert.Equal(t, 2, report.Failed)
+	assert.Equal(t, ImportRow{Row: 1, Status: ImportInserted}, report.Rows[0])
+	assert.Equal(t, "validation_failed", report.Rows[1].Code)
+	assert.Equal(t, "email", report.Rows[1].Fields[0].Field)
+	assert.Equal(t, "age", report.Rows[2].Fields[0].Field)
+	assert.Equal(t, int64(2), count())
+
+	w, report = importTest(app, "?dry_run=true", "text/csv", "email,name,age\ndee@example.com,dee,41\nann@example.com,ann,\n")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, true, report.DryRun)
+	assert.Equal(t, ImportRow{Row: 1, Status: ImportInserted}, report.Rows[0])
+	assert.Equal(t, "duplicate", report.Rows[1].Code)
+	assert.Equal(t, int64(2), count())
+
+	ann, err := provider.FindFirst(ctx, &FindConditions{Filters: []FilterFunc{Eq("email", "ann@example.com")}})
+	assert.Equal(t, nil, err)
+	id := strconv.FormatInt(ann.ID, 10)
+	_, report = importTest(app, "?on_conflict=update", "application/x-ndjson",
+		`{"id":`+id+`,"email":"ann@example.com","name":"anne"}`+"\n\n{broken\n"+`{"email":"eve@example.com"}`)
+	assert.Equal(t, []ImportRow{
+		{Row: 1, Status: ImportUpdated},
+		{Row: 2, Status: ImportFailed, Code: "bad_request", Error: "import: invalid JSON"},
+		{Row: 3, Status: ImportInserted},
+	}, report.Rows)
+	ann, err = provider.FindOne(ctx, ann.ID)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "anne", ann.Name)
+
+	_, report = importTest(app, "?on_conflict=skip", "application/json",
+		`[{"id":`+id+`,"email":"ann@example.com"},{"email":"eve@example.com"},{"email":"fay@example.com"}]`)
+	assert.Equal(t, 2, report.Skipped)
+	assert.Equal(t, 1, report.Inserted)
+	assert.Equal(t, int64(4), count())
+
+	// rows are bound like the body of a POST
+	_, report = importTest(app, "", "application/json",
+		`[{"id":999,"email":"gus@example.com","created_at":"2000-01-01T00:00:00Z","deleted_at":"2000-01-01T00:00:00Z"}]`)
+	assert.Equal(t, 1, report.Inserted)
+	gus, err := provider.FindFirst(ctx, &FindConditions{Filters: []FilterFunc{Eq("email", "gus@example.com")}})
+	assert.Equal(t, nil, err)
+	assert.NotEqual(t, int64(999), gus.ID)
+	assert.NotEqual(t, 2000, gus.CreatedAt.Year())
+	withInput := gin.New()
+	(&ResourceController[testContact]{
+		Provider:    provider,
+		Group:       withInput.Group("/contacts"),
+		CreateInput: CopyInput[testContactInput, testContact](),
+		Import:      true,
+	}).Register()
+	_, report = importTest(withInput, "", "application/json", `[{"email":"hal@example.com","name":"hal","age":30}]`)
+	assert.Equal(t, 1, report.Inserted)
+	hal, err := provider.FindFirst(ctx, &FindConditions{Filters: []FilterFunc{Eq("email", "hal@example.com")}})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, hal.Age)
+
+	w, _ = importTest(app, "", "text/csv", "email,phone\n")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w, _ = importTest(app, "?on_conflict=merge", "application/json", `[]`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,110 @@
+package rest
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ospiper/ginx/dbx"
+)
+
+// Binder binds the body of a create or update request into dst.
+type Binder[T any] func(c *gin.Context, dst *T) error
+
+var serverManaged = []reflect.Type{
+	reflect.TypeFor[dbx.Model](),
+	reflect.TypeFor[dbx.UUIDModel](),
+	reflect.TypeFor[dbx.Deletable](),
+	reflect.TypeFor[dbx.Permanent](),
+}
+
+// BindModel binds the body straight into T, ignoring the server managed fields
+// (ID and timestamps) of an embedded dbx.Model, dbx.UUIDModel, dbx.Deletable or dbx.Permanent.
+// It is the default Binder of ResourceController.
+func BindModel[T any](c *gin.Context, dst *T) error {
+	if err := c.ShouldBind(dst); err != nil {
+		return err
+	}
+	resetServerManaged(reflect.ValueOf(dst).Elem())
+	return nil
+}
+
+func resetServerManaged(v reflect.Value) {
+	if v.Kind() != reflect.Struct {
+		return
+	}
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+		if !f.Anonymous {
+			continue
+		}
+		if slices.Contains(serverManaged, f.Type) {
+			v.Field(i).SetZero()
+		} else if f.Type.Kind() == reflect.Struct {
+			resetServerManaged(v.Field(i))
+		}
+	}
+}
+
+// BindInput binds the body into the input type TIn, e.g. a create DTO, and maps it into dst.
+func BindInput[TIn, T any](mapper func(in *TIn, dst *T) error) Binder[T] {
+	return func(c *gin.Context, dst *T) error {
+		in := new(TIn)
+		if err := c.ShouldBind(in); err != nil {
+			return err
+		}
+		return mapper(in, dst)
+	}
+}
+
+// CopyInput is BindInput copying every exported field of TIn into the field of T with the same name,
+// or the name given by a `copy:"Name"` tag. Nil pointer fields of TIn are skipped,
+// which makes pointer DTOs suitable for partial updates.
+func CopyInput[TIn, T any]() Binder[T] {
+	return BindInput(func(in *TIn, dst *T) error {
+		return copyFields(reflect.ValueOf(in).Elem(), reflect.ValueOf(dst).Elem())
+	})
+}
+
+func copyFields(src, dst reflect.Value) error {
+	for i := 0; i < src.NumField(); i++ {
+		f := src.Type().Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		sv := src.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			if err := copyFields(sv, dst); err != nil {
+				return err
+			}
+			continue
+		}
+		name := f.Name
+		if tag := f.Tag.Get("copy"); tag == "-" {
+			continue
+		} else if tag != "" {
+			name = tag
+		}
+		dv := dst.FieldByName(name)
+		if !dv.IsValid() || !dv.CanSet() {
+			return fmt.Errorf("copy input: %s has no field %s", dst.Type(), name)
+		}
+		if sv.Kind() == reflect.Ptr && dv.Kind() != reflect.Ptr {
+			if sv.IsNil() {
+				continue
+			}
+			sv = sv.Elem()
+		}
+		switch {
+		case sv.Type().AssignableTo(dv.Type()):
+			dv.Set(sv)
+		case sv.Type().ConvertibleTo(dv.Type()):
+			dv.Set(sv.Convert(dv.Type()))
+		default:
+			return fmt.Errorf("copy input: cannot copy %s into %s.%s", sv.Type(), dst.Type(), name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package rest
+
+import (
+	"context"
+	"encoding"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+
+	"github.com/ospiper/ginx/dbx"
+)
+
+// Composite primary keys are addressed by a key struct used as ID, e.g.
+//
+//	type UserRoleKey struct {
+//		UserID int64 `uri:"user_id" binding:"required"`
+//		RoleID int64 `uri:"role_id" binding:"required"`
+//	}
+//
+// Its fields are matched by name with the primary key fields of the model. Routes address
+// such keys with one segment per field, /:user_id/:role_id, or with a single compound
+// segment, /1,2, when ResourceControllerOf.KeySeparator is set.
+
+// isKeyStruct reports whether ID is a composite key struct rather than a single value.
+func isKeyStruct[ID any]() bool {
+	t := reflect.TypeFor[ID]()
+	return t.Kind() == reflect.Struct && !reflect.PointerTo(t).Implements(reflect.TypeFor[encoding.TextUnmarshaler]())
+}
+
+// keyParams returns the uri names of the fields of the key struct ID.
+func keyParams[ID any]() []string {
+	t := reflect.TypeFor[ID]()
+	ret := make([]string, t.NumField())
+	for i := range ret {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("uri"), ",")
+		if name == "" {
+			panic(fmt.Sprintf("key %s: field %s has no uri tag", t, t.Field(i).Name))
+		}
+		ret[i] = name
+	}
+	return ret
+}
+
+// keyPath returns the relative route path addressing a record keyed by ID,
+// named param unless ID is addressed with one segment per field.
+func keyPath[ID any](separator, param string) string {
+	if !isKeyStruct[ID]() || separator != "" {
+		return ":" + param
+	}
+	params := keyParams[ID]()
+	for i, p := range params {
+		params[i] = ":" + p
+	}
+	return strings.Join(params, "/")
+}
+
+// bindKey binds the key of the record a request addresses, see keyPath.
+func bindKey[ID comparable](c *gin.Context, separator, param string) (ID, error) {
+	if !isKeyStruct[ID]() {
+		params := &IDInPath[ID]{}
+		err := binding.Uri.BindUri(map[string][]string{"id": {c.Param(param)}}, params)
+		if err != nil {
+			return params.ID, err
+		}
+		return params.ID, binding.Validator.ValidateStruct(params)
+	}
+	var id ID
+	if separator == "" {
+		err := c.ShouldBindUri(&id)
+		return id, err
+	}
+	names := keyParams[ID]()
+	parts := strings.Split(c.Param(param), separator)
+	if len(parts) != len(names) {
+		return id, fmt.Errorf("id: expect %d parts separated by %q", len(names), separator)
+	}
+	m := make(map[string][]string, len(names))
+	for i, name := range names {
+		m[name] = []string{parts[i]}
+	}
+	if err := binding.Uri.BindUri(m, &id); err != nil {
+		return id, err
+	}
+	return id, binding.Validator.ValidateStruct(&id)
+}
+
+// keyField pairs a field of ID, -1 for single value keys, with the primary key field of T it addresses.
+type keyField struct {
+	index int
+	field *schema.Field
+}
+
+func (w *providerImpl[T, ID]) keyFields() ([]keyField, error) {
+	sch, err := w.schema()
+	if err != nil {
+		return nil, err
+	}
+	return keyFieldsOf[ID](sch)
+}
+
+// keyFieldsOf returns the fields of sch addressed by ID.
+func keyFieldsOf[ID any](sch *schema.Schema) ([]keyField, error) {
+	if !isKeyStruct[ID]() {
+		if sch.PrioritizedPrimaryField == nil {
+			return nil, fmt.Errorf("%s has no single primary key", sch.Name)
+		}
+		return []keyField{{index: -1, field: sch.PrioritizedPrimaryField}}, nil
+	}
+	t := reflect.TypeFor[ID]()
+	ret := make([]keyField, t.NumField())
+	for i := range ret {
+		f := sch.LookUpField(t.Field(i).Name)
+		if f == nil || !f.PrimaryKey {
+			return nil, fmt.Errorf("key %s: %s is not a primary key field of %s", t, t.Field(i).Name, sch.Name)
+		}
+		ret[i] = keyField{index: i, field: f}
+	}
+	if len(ret) != len(sch.PrimaryFields) {
+		return nil, fmt.Errorf("key %s does not cover the primary key of %s", t, sch.Name)
+	}
+	return ret, nil
+}
+
+// keyWhere returns the condition matching the records with ids.
+func (w *providerImpl[T, ID]) keyWhere(ids ...ID) (clause.Expression, error) {
+	fields, err := w.keyFields()
+	if err != nil {
+		return nil, err
+	}
+	column := func(f keyField) clause.Column {
+		return clause.Column{Table: clause.CurrentTable, Name: f.field.DBName}
+	}
+	if fields[0].index < 0 {
+		if len(ids) == 1 {
+			return clause.Eq{Column: column(fields[0]), Value: ids[0]}, nil
+		}
+		values := make([]any, len(ids))
+		for i, id := range ids {
+			values[i] = id
+		}
+		return clause.IN{Column: column(fields[0]), Values: values}, nil
+	}
+	conds := make([]clause.Expression, len(ids))
+	for i, id := range ids {
+		rv := reflect.ValueOf(id)
+		eqs := make([]clause.Expression, len(fields))
+		for j, f := range fields {
+			eqs[j] = clause.Eq{Column: column(f), Value: rv.Field(f.index).Interface()}
+		}
+		conds[i] = clause.And(eqs...)
+	}
+	if len(conds) == 1 {
+		return conds[0], nil
+	}
+	return clause.Or(conds...), nil
+}
+
+// keyOf returns the key of v.
+func (w *providerImpl[T, ID]) keyOf(ctx context.Context, v *T) (ID, error) {
+	fields, err := w.keyFields()
+	if err != nil {
+		var id ID
+		return id, err
+	}
+	return keyValue[T, ID](ctx, fields, v)
+}
+
+// keyValue reads the key of v from its fields.
+func keyValue[T any, ID comparable](ctx context.Context, fields []keyField, v *T) (ID, error) {
+	var id ID
+	rv := reflect.ValueOf(v).Elem()
+	if fields[0].index < 0 {
+		val, _ := fields[0].field.ValueOf(ctx, rv)
+		id, ok := val.(ID)
+		if !ok {
+			return id, fmt.Errorf("key of %T is %T, not %T", v, val, id)
+		}
+		return id, nil
+	}
+	kv := reflect.ValueOf(&id).Elem()
+	for _, f := range fields {
+		val, _ := f.field.ValueOf(ctx, rv)
+		fv := reflect.ValueOf(val)
+		dst := kv.Field(f.index)
+		if !fv.Type().ConvertibleTo(dst.Type()) {
+			return id, fmt.Errorf("key field %s of %T is %s, not %s", f.field.Name, v, fv.Type(), dst.Type())
+		}
+		dst.Set(fv.Convert(dst.Type()))
+	}
+	return id, nil
+}
+
+// keyer derives the keys of the records of T. The providers of this package implement it, the
+// decorators wrapping one pass it through.
+type keyer[T any, ID comparable] interface {
+	keyOf(ctx context.Context, v *T) (ID, error)
+}
+
+// keyOf returns the key of v, a record provided by p. The keys of providers implemented
+// elsewhere are read from the primary key of T.
+func keyOf[T dbx.ModelStructOf[T, ID], ID comparable](ctx context.Context, p ProviderOf[T, ID], v *T) (ID, error) {
+	if k, ok := p.(keyer[T, ID]); ok {
+		return k.keyOf(ctx, v)
+	}
+	var id ID
+	sch, err := schemaOf[T](p.GetDB())
+	if err != nil {
+		return id, err
+	}
+	fields, err := keyFieldsOf[ID](sch)
+	if err != nil {
+		return id, err
+	}
+	return keyValue[T, ID](ctx, fields, v)
+}
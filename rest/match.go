@@ -0,0 +1,334 @@
+package rest
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// matcher tells whether a record satisfies filters without asking the database.
+type matcher func(ctx context.Context, rv reflect.Value) bool
+
+// matcherOf compiles filters on the records described by sch, the way PostgreSQL evaluates them.
+// It understands the expressions of the filter verbs but full text search, and the Eq, Neq, IN,
+// Like, Gt, Gte, Lt and Lte clauses, combined with And, Or and single Not conditions.
+func matcherOf(sch *schema.Schema, filters []FilterFunc) (matcher, error) {
+	exprs, err := ApplyFilterFunc(filters)
+	if err != nil {
+		return nil, err
+	}
+	return matchAll(sch, exprs)
+}
+
+func matchAll(sch *schema.Schema, exprs []clause.Expression) (matcher, error) {
+	ms := make([]matcher, len(exprs))
+	for i, expr := range exprs {
+		m, err := matchExpr(sch, expr)
+		if err != nil {
+			return nil, err
+		}
+		ms[i] = m
+	}
+	return func(ctx context.Context, rv reflect.Value) bool {
+		for _, m := range ms {
+			if !m(ctx, rv) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+func matchExpr(sch *schema.Schema, expr clause.Expression) (matcher, error) {
+	switch e := expr.(type) {
+	case clause.AndConditions:
+		return matchAll(sch, e.Exprs)
+	case clause.Where:
+		return matchAll(sch, e.Exprs)
+	case clause.OrConditions:
+		ms := make([]matcher, len(e.Exprs))
+		for i, expr := range e.Exprs {
+			m, err := matchExpr(sch, expr)
+			if err != nil {
+				return nil, err
+			}
+			ms[i] = m
+		}
+		return func(ctx context.Context, rv reflect.Value) bool {
+			for _, m := range ms {
+				if m(ctx, rv) {
+					return true
+				}
+			}
+			return false
+		}, nil
+	case clause.NotConditions:
+		// gorm renders several negated expressions differently depending on their types
+		if len(e.Exprs) != 1 {
+			return nil, fmt.Errorf("cannot match %T of %d expressions", expr, len(e.Exprs))
+		}
+		m, err := matchExpr(sch, e.Exprs[0])
+		if err != nil {
+			return nil, err
+		}
+		return func(ctx context.Context, rv reflect.Value) bool {
+			return !m(ctx, rv)
+		}, nil
+	case clause.Eq:
+		return matchColumn(sch, e.Column, func(v any) bool { return equals(v, e.Value) })
+	case clause.Neq:
+		return matchColumn(sch, e.Column, func(v any) bool {
+			if eqNil(e.Value) {
+				return v != nil
+			}
+			return v != nil && !equals(v, e.Value)
+		})
+	case clause.IN:
+		return matchColumn(sch, e.Column, func(v any) bool { return equals(v, e.Values) })
+	case clause.Like:
+		re, err := likePattern(e.Value, false)
+		if err != nil {
+			return nil, err
+		}
+		return matchColumn(sch, e.Column, func(v any) bool { return matchString(re, v) })
+	case clause.Gt:
+		return matchColumn(sch, e.Column, ordered(e.Value, func(c int) bool { return c > 0 }))
+	case clause.Gte:
+		return matchColumn(sch, e.Column, ordered(e.Value, func(c int) bool { return c >= 0 }))
+	case clause.Lt:
+		return matchColumn(sch, e.Column, ordered(e.Value, func(c int) bool { return c < 0 }))
+	case clause.Lte:
+		return matchColumn(sch, e.Column, ordered(e.Value, func(c int) bool { return c <= 0 }))
+	case clause.Expr:
+		return matchSQL(sch, e)
+	}
+	return nil, fmt.Errorf("cannot match %T", expr)
+}
+
+// matchSQL matches the expressions built by IsNull, ILike, Regex and Between.
+func matchSQL(sch *schema.Schema, e clause.Expr) (matcher, error) {
+	if len(e.Vars) == 0 {
+		return nil, fmt.Errorf("cannot match %q", e.SQL)
+	}
+	column := e.Vars[0]
+	switch {
+	case e.SQL == "? IS NULL" && len(e.Vars) == 1:
+		return matchColumn(sch, column, func(v any) bool { return v == nil })
+	case e.SQL == "? ILIKE ?" && len(e.Vars) == 2:
+		re, err := likePattern(e.Vars[1], true)
+		if err != nil {
+			return nil, err
+		}
+		return matchColumn(sch, column, func(v any) bool { return matchString(re, v) })
+	case e.SQL == "? ~* ?" && len(e.Vars) == 2:
+		pattern, ok := e.Vars[1].(string)
+		if !ok {
+			return nil, fmt.Errorf("regex: pattern is %T, not a string", e.Vars[1])
+		}
+		re, err := regexp.Compile("(?i)" + pattern)
+		if err != nil {
+			return nil, err
+		}
+		return matchColumn(sch, column, func(v any) bool { return matchString(re, v) })
+	case e.SQL == "? between (?, ?)" && len(e.Vars) == 3:
+		low := ordered(e.Vars[1], func(c int) bool { return c >= 0 })
+		high := ordered(e.Vars[2], func(c int) bool { return c <= 0 })
+		return matchColumn(sch, column, func(v any) bool { return low(v) && high(v) })
+	}
+	return nil, fmt.Errorf("cannot match %q", e.SQL)
+}
+
+// matchColumn applies fn to the value of column, nil for NULL.
+func matchColumn(sch *schema.Schema, column any, fn func(v any) bool) (matcher, error) {
+	var name string
+	switch col := column.(type) {
+	case string:
+		name = col
+	case clause.Column:
+		name = col.Name
+	default:
+		return nil, fmt.Errorf("cannot match column %T", column)
+	}
+	f := sch.LookUpField(name)
+	if f == nil {
+		return nil, fmt.Errorf("%s has no column %s", sch.Name, name)
+	}
+	return func(ctx context.Context, rv reflect.Value) bool {
+		v, _ := f.ValueOf(ctx, rv)
+		return fn(normalize(v))
+	}, nil
+}
+
+// ordered returns whether a value compares to operand as ok wants it. NULL and values of
+// another kind never do.
+func ordered(operand any, ok func(c int) bool) func(v any) bool {
+	return func(v any) bool {
+		c, comparable := compare(v, normalize(operand))
+		return comparable && ok(c)
+	}
+}
+
+// equals reports whether v equals operand, or one of its elements when it is a slice.
+func equals(v, operand any) bool {
+	if eqNil(operand) {
+		return v == nil
+	}
+	rv := reflect.ValueOf(operand)
+	if rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() != reflect.Uint8 {
+		for i := 0; i < rv.Len(); i++ {
+			if equals(v, rv.Index(i).Interface()) {
+				return true
+			}
+		}
+		return false
+	}
+	c, ok := compare(v, normalize(operand))
+	return ok && c == 0
+}
+
+// normalize turns a value into nil, a float64, a string, a bool or a time.Time when it can.
+func normalize(v any) any {
+	if valuer, ok := v.(driver.Valuer); ok {
+		if rv := reflect.ValueOf(v); rv.Kind() == reflect.Pointer && rv.IsNil() {
+			return nil
+		}
+		dv, err := valuer.Value()
+		if err != nil {
+			return v
+		}
+		v = dv
+	}
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return nil
+	}
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return rv.Float()
+	case reflect.String:
+		return rv.String()
+	case reflect.Bool:
+		return rv.Bool()
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return string(rv.Bytes())
+		}
+	}
+	return rv.Interface()
+}
+
+// compare orders normalized values, parsing operands given as strings, e.g. in JSON filters,
+// into the kind of v. It returns false if they cannot be compared.
+func compare(v, operand any) (int, bool) {
+	if v == nil || operand == nil {
+		return 0, false
+	}
+	switch x := v.(type) {
+	case float64:
+		y, ok := operand.(float64)
+		if s, isString := operand.(string); isString {
+			f, err := strconv.ParseFloat(s, 64)
+			y, ok = f, err == nil
+		}
+		if !ok {
+			return 0, false
+		}
+		return cmpOrdered(x, y), true
+	case string:
+		y, ok := operand.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(x, y), true
+	case bool:
+		y, ok := operand.(bool)
+		if s, isString := operand.(string); isString {
+			b, err := strconv.ParseBool(s)
+			y, ok = b, err == nil
+		}
+		if !ok || x == y {
+			return 0, ok
+		}
+		if !x {
+			return -1, true
+		}
+		return 1, true
+	case time.Time:
+		y, ok := operand.(time.Time)
+		if s, isString := operand.(string); isString {
+			t, err := time.Parse(time.RFC3339Nano, s)
+			y, ok = t, err == nil
+		}
+		if !ok {
+			return 0, false
+		}
+		return x.Compare(y), true
+	}
+	return 0, false
+}
+
+func cmpOrdered(x, y float64) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+// likePattern compiles a LIKE pattern, where % matches any string and _ any character.
+func likePattern(pattern any, fold bool) (*regexp.Regexp, error) {
+	s, ok := pattern.(string)
+	if !ok {
+		return nil, fmt.Errorf("like: pattern is %T, not a string", pattern)
+	}
+	var b strings.Builder
+	b.WriteString("^(?s)")
+	if fold {
+		b.WriteString("(?i)")
+	}
+	for _, r := range s {
+		switch r {
+		case '%':
+			b.WriteString(".*")
+		case '_':
+			b.WriteByte('.')
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteByte('$')
+	return regexp.Compile(b.String())
+}
+
+func matchString(re *regexp.Regexp, v any) bool {
+	s, ok := v.(string)
+	return ok && re.MatchString(s)
+}
+
+func eqNil(v any) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	return rv.Kind() == reflect.Pointer && rv.IsNil()
+}
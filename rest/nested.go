@@ -0,0 +1,408 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+
+	"github.com/ospiper/ginx"
+	"github.com/ospiper/ginx/dbx"
+)
+
+// NestedController registers the routes of the association name of TBase under
+// /<base>/:id/<name>, serving its records through nestController:
+//
+//	GET    /drives/:id/tags          lists the associated records
+//	POST   /drives/:id/tags          creates a record linked to the parent
+//	PUT    /drives/:id/tags          links exactly the records whose ids are in the body
+//	PUT    /drives/:id/tags/:child   links an existing record
+//	DELETE /drives/:id/tags/:child   unlinks the record, deleting it unless the association is many2many
+//
+// Writes require the base policy to allow updating the parent. Linking a has-one or has-many
+// record, or dropping it from the association, updates its foreign key: the nested policy must
+// allow updating it, and linking runs the update hooks of nestController. Foreign keys are set
+// after the field guard of nestController has run, so write-protected ones are linked too.
+// Belongs-to associations
+// are read only. Replacing a has-one or has-many association nulls the foreign key of
+// the records it drops, so it fails when that column is not nullable.
+func NestedController[TBase dbx.ModelStructOf[TBase, BID], TNest dbx.ModelStructOf[TNest, NID], BID, NID comparable](baseController *ResourceControllerOf[TBase, BID], nestController *ResourceControllerOf[TNest, NID], name string) {
+	ginx.Validator()
+	rel, err := relationOf(baseController.Provider.GetDB(), new(TBase), name)
+	if err != nil {
+		panic(err)
+	}
+	n := &nested[TBase, TNest, BID, NID]{
+		base: baseController,
+		nest: nestController,
+		name: name,
+		rel:  rel,
+	}
+	nestBaseGroup := baseController.Group.Group(keyPath[BID](baseController.KeySeparator, "id")).Group(strings.ToLower(name))
+	// controllers coping with nested (foreign key restraint) structures
+	// should not be nested in the API, it should directly be /tags/:id
+	baseURI := keyURI[BID](baseController.KeySeparator, "id")
+	childURI := joinURI(baseURI, keyURI[NID](nestController.KeySeparator, "child_id"))
+	n.handle(nestBaseGroup, http.MethodGet, "", n.list, "list", ginx.Operation{ // /drives/:id/tags
+		URI:   baseURI,
+		Query: reflect.TypeFor[SimpleRestQuery](),
+		List:  true,
+	})
+	if rel.Type == schema.BelongsTo {
+		methodNotAllowed(nestBaseGroup, http.MethodPost, http.MethodGet)
+		methodNotAllowed(nestBaseGroup, http.MethodPut, http.MethodGet)
+		return
+	}
+	n.handle(nestBaseGroup, http.MethodPost, "", n.create, "create", ginx.Operation{ // /drives/:id/tags
+		URI:     baseURI,
+		Request: reflect.TypeFor[TNest](),
+		Status:  http.StatusCreated,
+	})
+	n.handle(nestBaseGroup, http.MethodPut, "", n.replace, "replace", ginx.Operation{ // /drives/:id/tags
+		URI:     baseURI,
+		Request: reflect.TypeFor[[]NID](),
+		List:    true,
+	})
+	childGroup := nestBaseGroup.Group(keyPath[NID](nestController.KeySeparator, "child_id"))
+	n.handle(childGroup, http.MethodPut, "", n.attach, "attach", ginx.Operation{URI: childURI}) // /drives/:id/tags/:child_id
+	n.handle(childGroup, http.MethodDelete, "", n.detach, "detach", ginx.Operation{             // /drives/:id/tags/:child_id
+		URI:    childURI,
+		Status: http.StatusNoContent,
+	})
+}
+
+type nested[TBase dbx.ModelStructOf[TBase, BID], TNest dbx.ModelStructOf[TNest, NID], BID, NID comparable] struct {
+	base *ResourceControllerOf[TBase, BID]
+	nest *ResourceControllerOf[TNest, NID]
+	name string
+	rel  *schema.Relationship
+}
+
+// relationOf returns the relationship name of the model of value.
+func relationOf(db *gorm.DB, value any, name string) (*schema.Relationship, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(value); err != nil {
+		return nil, err
+	}
+	rel, ok := stmt.Schema.Relationships.Relations[name]
+	if !ok {
+		return nil, fmt.Errorf("rest: %s has no association %s", stmt.Schema.Name, name)
+	}
+	return rel, nil
+}
+
+// handle registers h on group, documented as the operation verb on the association, returning
+// TNest unless op says otherwise.
+func (n *nested[TBase, TNest, BID, NID]) handle(group *gin.RouterGroup, method, relativePath string, h gin.HandlerFunc, verb string, op ginx.Operation) {
+	op.Name = verb + exportedName(n.base.resource()) + exportedName(n.name)
+	op.Summary = verb + " " + strings.ToLower(n.name) + " of " + n.base.resource()
+	op.Tags = []string{n.base.resource()}
+	if op.Response == nil && op.Status != http.StatusNoContent {
+		op.Response = reflect.TypeFor[TNest]()
+	}
+	ginx.Handle(group, method, relativePath, negotiated(h), op)
+}
+
+// parent loads the parent addressed by the request for a write to its association.
+func (n *nested[TBase, TNest, BID, NID]) parent(ctx context.Context, c *gin.Context) (*TBase, error) {
+	id, err := bindKey[BID](c, n.base.KeySeparator, "id")
+	if err != nil {
+		return nil, ginx.BindError(err)
+	}
+	ctx = n.base.scope(ctx)
+	parent, err := n.base.Provider.FindOne(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if n.base.Policy != nil && !n.base.Policy.CanUpdate(ctx, parent) {
+		return nil, ErrForbidden
+	}
+	return parent, nil
+}
+
+// child loads the record addressed by the child_id segment.
+func (n *nested[TBase, TNest, BID, NID]) child(ctx context.Context, c *gin.Context) (NID, *TNest, error) {
+	id, err := bindKey[NID](c, n.nest.KeySeparator, "child_id")
+	if err != nil {
+		return id, nil, ginx.BindError(err)
+	}
+	v, err := n.nest.Provider.FindOne(ctx, id)
+	if err != nil {
+		return id, nil, err
+	}
+	if n.nest.Policy != nil && !n.nest.Policy.CanRead(ctx, v) {
+		return id, nil, ErrForbidden
+	}
+	return id, v, nil
+}
+
+// for OneToMany relations, is it necessary to keep interfaces like GET /tags and POST /tags
+// to get all or to create a new tag even it might be meaningless?
+func (n *nested[TBase, TNest, BID, NID]) list(c *gin.Context) {
+	id, err := bindKey[BID](c, n.base.KeySeparator, "id")
+	if err != nil {
+		writer(c).Error(c, ginx.BindError(err))
+		return
+	}
+	cond, err := BuildSimpleRestConditions(c)
+	if err != nil {
+		writer(c).Error(c, ginx.BindError(err))
+		return
+	}
+	base := ginx.RequestContext(c)
+	if n.base.Policy != nil {
+		parent, err := n.base.Provider.FindOne(n.base.scope(base), id)
+		if err != nil {
+			writer(c).Error(c, err)
+			return
+		}
+		if !n.base.Policy.CanRead(base, parent) {
+			writer(c).Error(c, ErrForbidden)
+			return
+		}
+	}
+	if n.nest.Policy != nil && !n.nest.Policy.CanList(base) {
+		writer(c).Error(c, ErrForbidden)
+		return
+	}
+	var parentModel TBase
+	p := parentModel.NewWithID(id)
+	ctx := n.nest.scope(base)
+	records, err := n.nest.Provider.FindAssoc(ctx, &p, n.name, cond)
+	if err != nil {
+		writer(c).Error(c, err)
+		return
+	}
+	cnt, err := n.nest.Provider.CountAssoc(ctx, &p, n.name, cond.Filters)
+	if err != nil {
+		writer(c).Error(c, err)
+		return
+	}
+	code, hd := PaginationHeader(cond.Pagination, cnt)
+	if hd != "" {
+		c.Header("Content-Range", hd)
+	}
+	n.nest.renderList(c, code, records, cnt)
+}
+
+func (n *nested[TBase, TNest, BID, NID]) create(c *gin.Context) {
+	var data TNest
+	err := bind(c, n.nest.CreateInput, &data)
+	if err != nil {
+		writer(c).Error(c, ginx.BindError(err))
+		return
+	}
+	err = dbx.Transaction(ginx.RequestContext(c), n.nest.Provider.GetDB(), func(ctx context.Context) error {
+		parent, err := n.parent(ctx, c)
+		if err != nil {
+			return err
+		}
+		ctx = n.nest.scope(ctx)
+		if n.rel.Type == schema.Many2Many {
+			if err := n.nest.insert(ctx, &data, nil); err != nil {
+				return err
+			}
+			return n.nest.Provider.AppendAssoc(ctx, parent, n.name, &data)
+		}
+		return n.nest.insert(ctx, &data, func(v *TNest) error {
+			return link(ctx, n.rel, parent, v)
+		})
+	})
+	if err != nil {
+		writer(c).Error(c, err)
+		return
+	}
+	n.nest.render(c, http.StatusCreated, &data)
+}
+
+func (n *nested[TBase, TNest, BID, NID]) replace(c *gin.Context) {
+	var ids []NID
+	err := c.ShouldBindJSON(&ids)
+	if err != nil {
+		writer(c).Error(c, ginx.BindError(err))
+		return
+	}
+	records := make([]*TNest, 0, len(ids))
+	err = dbx.Transaction(ginx.RequestContext(c), n.nest.Provider.GetDB(), func(ctx context.Context) error {
+		parent, err := n.parent(ctx, c)
+		if err != nil {
+			return err
+		}
+		ctx = n.nest.scope(ctx)
+		if n.rel.Type != schema.Many2Many {
+			if err := n.canDrop(ctx, parent, ids); err != nil {
+				return err
+			}
+		}
+		for _, id := range ids {
+			v, err := n.nest.Provider.FindOne(ctx, id)
+			if err != nil {
+				return err
+			}
+			if n.nest.Policy != nil && !n.nest.Policy.CanRead(ctx, v) {
+				return ErrForbidden
+			}
+			if n.rel.Type != schema.Many2Many && !owns(ctx, n.rel, parent, v) {
+				if v, err = n.relink(ctx, parent, id, v); err != nil {
+					return err
+				}
+			}
+			records = append(records, v)
+		}
+		return n.nest.Provider.ReplaceAssoc(ctx, parent, n.name, records...)
+	})
+	if err != nil {
+		writer(c).Error(c, err)
+		return
+	}
+	n.nest.renderList(c, http.StatusOK, records, int64(len(records)))
+}
+
+func (n *nested[TBase, TNest, BID, NID]) attach(c *gin.Context) {
+	var v *TNest
+	err := dbx.Transaction(ginx.RequestContext(c), n.nest.Provider.GetDB(), func(ctx context.Context) error {
+		parent, err := n.parent(ctx, c)
+		if err != nil {
+			return err
+		}
+		ctx = n.nest.scope(ctx)
+		var id NID
+		id, v, err = n.child(ctx, c)
+		if err != nil {
+			return err
+		}
+		if n.rel.Type != schema.Many2Many {
+			v, err = n.relink(ctx, parent, id, v)
+			return err
+		}
+		return n.nest.Provider.AppendAssoc(ctx, parent, n.name, v)
+	})
+	if err != nil {
+		writer(c).Error(c, err)
+		return
+	}
+	n.nest.render(c, http.StatusOK, v)
+}
+
+func (n *nested[TBase, TNest, BID, NID]) detach(c *gin.Context) {
+	err := dbx.Transaction(ginx.RequestContext(c), n.nest.Provider.GetDB(), func(ctx context.Context) error {
+		parent, err := n.parent(ctx, c)
+		if err != nil {
+			return err
+		}
+		ctx = n.nest.scope(ctx)
+		id, v, err := n.child(ctx, c)
+		if err != nil {
+			return err
+		}
+		if n.rel.Type == schema.Many2Many {
+			return n.nest.Provider.RemoveAssoc(ctx, parent, n.name, v)
+		}
+		if !owns(ctx, n.rel, parent, v) {
+			return ErrNotFound
+		}
+		return n.nest.remove(ctx, id, v)
+	})
+	if err != nil {
+		writer(c).Error(c, err)
+		return
+	}
+	writer(c).Success(c, http.StatusNoContent, nil)
+}
+
+// relink points the foreign key of child, the record keyed by id, at parent as an update of
+// child, which the nested policy must allow, returning the updated record.
+func (n *nested[TBase, TNest, BID, NID]) relink(ctx context.Context, parent *TBase, id NID, child *TNest) (*TNest, error) {
+	var data TNest
+	err := n.nest.modify(ctx, id, child, &data, func(v *TNest) error {
+		return link(ctx, n.rel, parent, v)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return n.nest.Provider.FindOne(ctx, id)
+}
+
+// canDrop checks that the nested policy allows updating the records of parent which replacing
+// its association by ids unlinks.
+func (n *nested[TBase, TNest, BID, NID]) canDrop(ctx context.Context, parent *TBase, ids []NID) error {
+	if n.nest.Policy == nil {
+		return nil
+	}
+	current, err := n.nest.Provider.FindAssoc(ctx, parent, n.name, nil)
+	if err != nil {
+		return err
+	}
+	for _, v := range current {
+		id, err := keyOf(ctx, n.nest.Provider, v)
+		if err != nil {
+			return err
+		}
+		if !slices.Contains(ids, id) && !n.nest.Policy.CanUpdate(ctx, v) {
+			return ErrForbidden
+		}
+	}
+	return nil
+}
+
+// link points the foreign key of child, a record of the has-one or has-many relationship rel, at parent.
+func link(ctx context.Context, rel *schema.Relationship, parent, child any) error {
+	pv, cv := reflect.Indirect(reflect.ValueOf(parent)), reflect.Indirect(reflect.ValueOf(child))
+	for _, ref := range rel.References {
+		var v any
+		switch {
+		case ref.OwnPrimaryKey:
+			v, _ = ref.PrimaryKey.ValueOf(ctx, pv)
+		case ref.PrimaryValue != "":
+			v = ref.PrimaryValue
+		default:
+			continue
+		}
+		if err := ref.ForeignKey.Set(ctx, cv, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// owns reports whether the foreign key of child points at parent.
+func owns(ctx context.Context, rel *schema.Relationship, parent, child any) bool {
+	pv, cv := reflect.Indirect(reflect.ValueOf(parent)), reflect.Indirect(reflect.ValueOf(child))
+	for _, ref := range rel.References {
+		var want any
+		switch {
+		case ref.OwnPrimaryKey:
+			want, _ = ref.PrimaryKey.ValueOf(ctx, pv)
+		case ref.PrimaryValue != "":
+			want = ref.PrimaryValue
+		default:
+			continue
+		}
+		got, _ := ref.ForeignKey.ValueOf(ctx, cv)
+		if got = deref(got); got == nil || fmt.Sprint(got) != fmt.Sprint(deref(want)) {
+			return false
+		}
+	}
+	return true
+}
+
+// deref follows the pointers of v, returning nil for a nil pointer.
+func deref(v any) any {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return nil
+	}
+	return rv.Interface()
+}
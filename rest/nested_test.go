@@ -0,0 +1,256 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/assert/v2"
+
+	"github.com/ospiper/ginx/dbx"
+)
+
+type testProject struct {
+	dbx.Model
+	Name    string        `json:"name"`
+	Tasks   []*testTask   `json:"tasks,omitempty" gorm:"foreignKey:ProjectID"`
+	Members []*testMember `json:"members,omitempty" gorm:"many2many:test_project_members"`
+}
+
+func (testProject) NewWithID(id int64) testProject {
+	return testProject{Model: dbx.Model{ID: id}}
+}
+
+type testTask struct {
+	dbx.Model
+	ProjectID int64  `json:"project_id"`
+	Title     string `json:"title"`
+}
+
+func (testTask) NewWithID(id int64) testTask {
+	return testTask{Model: dbx.Model{ID: id}}
+}
+
+type testMember struct {
+	dbx.Model
+	Name string `json:"name"`
+}
+
+func (testMember) NewWithID(id int64) testMember {
+	return testMember{Model: dbx.Model{ID: id}}
+}
+
+func TestNestedControllerHasMany(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	projects := NewProvider[testProject](testDB)
+	tasks := NewProvider[testTask](testDB)
+	assert.Equal(t, nil, projects.Migrate())
+	assert.Equal(t, nil, tasks.Migrate())
+	ctx := context.Background()
+	p, other := &testProject{Name: "p"}, &testProject{Name: "other"}
+	assert.Equal(t, nil, projects.InsertMany(ctx, []*testProject{p, other}))
+	app := gin.New()
+	base := RegisterResourceController(app.Group("/projects"), projects)
+	nest := RegisterResourceController(app.Group("/tasks"), tasks)
+	NestedController(base, nest, "Tasks")
+	prefix := "/projects/" + strconv.FormatInt(p.ID, 10) + "/tasks"
+
+	w := serveTest(app, http.MethodPost, prefix, `{"title":"first","project_id":999}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var created testTask
+	assert.Equal(t, nil, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, p.ID, created.ProjectID)
+	w = serveTest(app, http.MethodPost, "/projects/99999/tasks", `{"title":"orphan"}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	foreign := &testTask{ProjectID: other.ID, Title: "foreign"}
+	assert.Equal(t, nil, tasks.Insert(ctx, foreign))
+	w = serveTest(app, http.MethodDelete, prefix+"/"+strconv.FormatInt(foreign.ID, 10), "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = serveTest(app, http.MethodPut, prefix+"/"+strconv.FormatInt(foreign.ID, 10), "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	moved, err := tasks.FindOne(ctx, foreign.ID)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, p.ID, moved.ProjectID)
+
+	w = serveTest(app, http.MethodDelete, prefix+"/"+strconv.FormatInt(created.ID, 10), "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	_, err = tasks.FindOne(ctx, created.ID)
+	assert.Equal(t, ErrNotFound, err)
+}
+
+type testTaskPolicy struct {
+	OpenPolicy[testTask]
+}
+
+func (testTaskPolicy) CanUpdate(_ context.Context, v *testTask) bool { return v.Title != "locked" }
+
+func (testTaskPolicy) CanReadField(context.Context, string) bool { return true }
+
+// tasks are only moved by linking them
+func (testTaskPolicy) CanWriteField(_ context.Context, field string) bool {
+	return field != "project_id"
+}
+
+func TestNestedControllerRelinkPolicy(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	projects := NewProvider[testProject](testDB)
+	tasks := NewProvider[testTask](testDB)
+	assert.Equal(t, nil, projects.Migrate())
+	assert.Equal(t, nil, tasks.Migrate())
+	ctx := context.Background()
+	p, other := &testProject{Name: "p"}, &testProject{Name: "other"}
+	assert.Equal(t, nil, projects.InsertMany(ctx, []*testProject{p, other}))
+	locked, open := &testTask{ProjectID: other.ID, Title: "locked"}, &testTask{ProjectID: other.ID, Title: "open"}
+	assert.Equal(t, nil, tasks.InsertMany(ctx, []*testTask{locked, open}))
+	app := gin.New()
+	base := RegisterResourceController(app.Group("/projects"), projects)
+	nest := &ResourceController[testTask]{Provider: tasks, Group: app.Group("/tasks"), Policy: testTaskPolicy{}}
+	nest.Register()
+	NestedController(base, nest, "Tasks")
+	prefix := "/projects/" + strconv.FormatInt(p.ID, 10) + "/tasks"
+	projectOf := func(v *testTask) int64 {
+		cur, err := tasks.FindOne(ctx, v.ID)
+		assert.Equal(t, nil, err)
+		return cur.ProjectID
+	}
+
+	// moving a task to another project updates it
+	w := serveTest(app, http.MethodPut, prefix+"/"+strconv.FormatInt(locked.ID, 10), "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, other.ID, projectOf(locked))
+	body, _ := json.Marshal([]int64{locked.ID})
+	w = serveTest(app, http.MethodPut, prefix, string(body))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, other.ID, projectOf(locked))
+
+	w = serveTest(app, http.MethodPut, prefix+"/"+strconv.FormatInt(open.ID, 10), "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var moved testTask
+	assert.Equal(t, nil, json.Unmarshal(w.Body.Bytes(), &moved))
+	assert.Equal(t, p.ID, moved.ProjectID)
+	assert.Equal(t, p.ID, projectOf(open))
+
+	// so does dropping one
+	body, _ = json.Marshal([]int64{open.ID})
+	w = serveTest(app, http.MethodPut, "/projects/"+strconv.FormatInt(other.ID, 10)+"/tasks", string(body))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, p.ID, projectOf(open))
+
+	// the write-protected foreign key is still set by creating a task
+	w = serveTest(app, http.MethodPost, prefix, `{"title":"new","project_id":`+strconv.FormatInt(other.ID, 10)+`}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var created testTask
+	assert.Equal(t, nil, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, p.ID, projectOf(&created))
+}
+
+func TestNestedControllerMany2Many(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	projects := NewProvider[testProject](testDB)
+	members := NewProvider[testMember](testDB)
+	assert.Equal(t, nil, projects.Migrate())
+	assert.Equal(t, nil, members.Migrate())
+	ctx := context.Background()
+	p := &testProject{Name: "team"}
+	assert.Equal(t, nil, projects.Insert(ctx, p))
+	alice, bob := &testMember{Name: "alice"}, &testMember{Name: "bob"}
+	assert.Equal(t, nil, members.InsertMany(ctx, []*testMember{alice, bob}))
+	app := gin.New()
+	base := RegisterResourceController(app.Group("/projects"), projects)
+	nest := RegisterResourceController(app.Group("/members"), members)
+	NestedController(base, nest, "Members")
+	prefix := "/projects/" + strconv.FormatInt(p.ID, 10) + "/members"
+	linked := func() int64 {
+		return testDB.Model(p).Association("Members").Count()
+	}
+
+	w := serveTest(app, http.MethodPost, prefix, `{"name":"carol"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, int64(1), linked())
+
+	w = serveTest(app, http.MethodPut, prefix+"/"+strconv.FormatInt(alice.ID, 10), "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, int64(2), linked())
+
+	body, _ := json.Marshal([]int64{alice.ID, bob.ID})
+	w = serveTest(app, http.MethodPut, prefix, string(body))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, int64(2), linked())
+	w = serveTest(app, http.MethodPut, prefix, `[0]`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, int64(2), linked())
+
+	w = serveTest(app, http.MethodDelete, prefix+"/"+strconv.FormatInt(bob.ID, 10), "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, int64(1), linked())
+	_, err := members.FindOne(ctx, bob.ID)
+	assert.Equal(t, nil, err)
+}
+
+func TestNestedControllerList(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	projects := NewProvider[testProject](testDB)
+	tasks := NewProvider[testTask](testDB)
+	members := NewProvider[testMember](testDB)
+	assert.Equal(t, nil, projects.Migrate())
+	assert.Equal(t, nil, tasks.Migrate())
+	assert.Equal(t, nil, members.Migrate())
+	ctx := context.Background()
+	p, other := &testProject{Name: "listed"}, &testProject{Name: "unlisted"}
+	assert.Equal(t, nil, projects.InsertMany(ctx, []*testProject{p, other}))
+	assert.Equal(t, nil, tasks.InsertMany(ctx, []*testTask{
+		{ProjectID: p.ID, Title: "a1"},
+		{ProjectID: p.ID, Title: "a2"},
+		{ProjectID: p.ID, Title: "a3"},
+		{ProjectID: p.ID, Title: "b1"},
+		{ProjectID: other.ID, Title: "a4"},
+	}))
+	ms := []*testMember{{Name: "m1"}, {Name: "m2"}, {Name: "m3"}, {Name: "m4"}}
+	assert.Equal(t, nil, members.InsertMany(ctx, ms))
+	assert.Equal(t, nil, members.AppendAssoc(ctx, p, "Members", ms[:3]...))
+	assert.Equal(t, nil, members.AppendAssoc(ctx, other, "Members", ms[3]))
+	app := gin.New()
+	base := RegisterResourceController(app.Group("/projects"), projects)
+	NestedController(base, RegisterResourceController(app.Group("/tasks"), tasks), "Tasks")
+	NestedController(base, RegisterResourceController(app.Group("/members"), members), "Members")
+	prefix := "/projects/" + strconv.FormatInt(p.ID, 10)
+
+	query := url.Values{
+		"filter": {`{"title_like":"a"}`},
+		"sort":   {`["title","desc"]`},
+		"range":  {`[0,1]`},
+	}
+	w := serveTest(app, http.MethodGet, prefix+"/tasks?"+query.Encode(), "")
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, "items 0-1/3", w.Header().Get("Content-Range"))
+	var ts []testTask
+	assert.Equal(t, nil, json.Unmarshal(w.Body.Bytes(), &ts))
+	assert.Equal(t, 2, len(ts))
+	assert.Equal(t, "a3", ts[0].Title)
+	assert.Equal(t, "a2", ts[1].Title)
+
+	query = url.Values{
+		"sort":  {`["name","asc"]`},
+		"range": {`[1,2]`},
+	}
+	w = serveTest(app, http.MethodGet, prefix+"/members?"+query.Encode(), "")
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, "items 1-2/3", w.Header().Get("Content-Range"))
+	var got []testMember
+	assert.Equal(t, nil, json.Unmarshal(w.Body.Bytes(), &got))
+	assert.Equal(t, 2, len(got))
+	assert.Equal(t, "m2", got[0].Name)
+	assert.Equal(t, "m3", got[1].Name)
+
+	w = serveTest(app, http.MethodGet, prefix+"/members?"+url.Values{"filter": {`{"name":"m4"}`}}.Encode(), "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "items 0-25/0", w.Header().Get("Content-Range"))
+	cnt, err := members.CountAssoc(ctx, other, "Members", []FilterFunc{Eq("name", "m4")})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(1), cnt)
+}
@@ -0,0 +1,176 @@
+package rest
+
+import (
+	"context"
+
+	"github.com/ospiper/ginx/dbx"
+)
+
+// Change is a write to a record of T seen by an ObservingProviderOf.
+type Change[T any, ID comparable] struct {
+	// Type is EventCreated, EventUpdated or EventDeleted.
+	Type string
+	Key  ID
+	// Before is the record before the write, nil for creations. After is the record after the
+	// write, read back whole for updates, nil for deletions.
+	Before, After *T
+}
+
+// ObservingProvider is ObservingProviderOf for models keyed by int64.
+type ObservingProvider[T dbx.ModelStruct[T]] = ObservingProviderOf[T, int64]
+
+// ObservingProviderOf decorates a provider to pass every record it inserts, updates and deletes
+// to Observe, in the transaction of the write: an error of Observe rolls the write back. Updated
+// and deleted records are read before the write, updated ones again after it. Records linked by
+// AppendAssoc and friends are not observed.
+//
+// PublishingProviderOf, OutboxProviderOf and AuditingProviderOf are built on it.
+type ObservingProviderOf[T dbx.ModelStructOf[T, ID], ID comparable] struct {
+	ProviderOf[T, ID]
+	Observe func(ctx context.Context, ch Change[T, ID]) error
+}
+
+func NewObservingProvider[T dbx.ModelStruct[T]](p Provider[T], observe func(ctx context.Context, ch Change[T, int64]) error) *ObservingProvider[T] {
+	return NewObservingProviderOf(p, observe)
+}
+
+// NewObservingProviderOf passes the writes made through p to observe.
+func NewObservingProviderOf[T dbx.ModelStructOf[T, ID], ID comparable](p ProviderOf[T, ID], observe func(ctx context.Context, ch Change[T, ID]) error) *ObservingProviderOf[T, ID] {
+	return &ObservingProviderOf[T, ID]{
+		ProviderOf: p,
+		Observe:    observe,
+	}
+}
+
+func (p *ObservingProviderOf[T, ID]) keyOf(ctx context.Context, v *T) (ID, error) {
+	return keyOf(ctx, p.ProviderOf, v)
+}
+
+// transaction runs fn in the transaction of ctx, or a new one.
+func (p *ObservingProviderOf[T, ID]) transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return dbx.Transaction(ctx, p.GetDB(), fn)
+}
+
+func (p *ObservingProviderOf[T, ID]) created(ctx context.Context, vs ...*T) error {
+	for _, v := range vs {
+		key, err := p.keyOf(ctx, v)
+		if err != nil {
+			return err
+		}
+		if err := p.Observe(ctx, Change[T, ID]{Type: EventCreated, Key: key, After: v}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *ObservingProviderOf[T, ID]) Insert(ctx context.Context, v *T) error {
+	return p.transaction(ctx, func(ctx context.Context) error {
+		if err := p.ProviderOf.Insert(ctx, v); err != nil {
+			return err
+		}
+		return p.created(ctx, v)
+	})
+}
+
+func (p *ObservingProviderOf[T, ID]) InsertMany(ctx context.Context, vs []*T) error {
+	return p.transaction(ctx, func(ctx context.Context) error {
+		if err := p.ProviderOf.InsertMany(ctx, vs); err != nil {
+			return err
+		}
+		return p.created(ctx, vs...)
+	})
+}
+
+func (p *ObservingProviderOf[T, ID]) InsertBatch(ctx context.Context, vs []*T, batchSize int) error {
+	return p.transaction(ctx, func(ctx context.Context) error {
+		if err := p.ProviderOf.InsertBatch(ctx, vs, batchSize); err != nil {
+			return err
+		}
+		return p.created(ctx, vs...)
+	})
+}
+
+// updated reads back the record keyed by id after an update, only the written fields of which
+// the provider returns, and observes it.
+func (p *ObservingProviderOf[T, ID]) updated(ctx context.Context, id ID, old *T) error {
+	cur, err := p.ProviderOf.FindOne(ctx, id)
+	if err != nil {
+		return err
+	}
+	return p.Observe(ctx, Change[T, ID]{Type: EventUpdated, Key: id, Before: old, After: cur})
+}
+
+func (p *ObservingProviderOf[T, ID]) Update(ctx context.Context, id ID, v *T) error {
+	return p.transaction(ctx, func(ctx context.Context) error {
+		old, err := p.ProviderOf.FindOne(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := p.ProviderOf.Update(ctx, id, v); err != nil {
+			return err
+		}
+		return p.updated(ctx, id, old)
+	})
+}
+
+func (p *ObservingProviderOf[T, ID]) UpdateFields(ctx context.Context, id ID, fields map[string]any) (*T, error) {
+	var ret *T
+	err := p.transaction(ctx, func(ctx context.Context) error {
+		old, err := p.ProviderOf.FindOne(ctx, id)
+		if err != nil {
+			return err
+		}
+		ret, err = p.ProviderOf.UpdateFields(ctx, id, fields)
+		if err != nil {
+			return err
+		}
+		return p.updated(ctx, id, old)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func (p *ObservingProviderOf[T, ID]) Delete(ctx context.Context, id ID) error {
+	return p.transaction(ctx, func(ctx context.Context) error {
+		old, err := p.ProviderOf.FindOne(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := p.ProviderOf.Delete(ctx, id); err != nil {
+			return err
+		}
+		return p.Observe(ctx, Change[T, ID]{Type: EventDeleted, Key: id, Before: old})
+	})
+}
+
+func (p *ObservingProviderOf[T, ID]) DeleteMany(ctx context.Context, ids []ID) ([]DeleteResult[ID], error) {
+	var results []DeleteResult[ID]
+	err := p.transaction(ctx, func(ctx context.Context) error {
+		olds := make(map[ID]*T, len(ids))
+		for _, id := range ids {
+			if v, err := p.ProviderOf.FindOne(ctx, id); err == nil {
+				olds[id] = v
+			}
+		}
+		var err error
+		results, err = p.ProviderOf.DeleteMany(ctx, ids)
+		if err != nil {
+			return err
+		}
+		for _, res := range results {
+			if old, ok := olds[res.ID]; ok && res.Deleted {
+				if err := p.Observe(ctx, Change[T, ID]{Type: EventDeleted, Key: res.ID, Before: old}); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
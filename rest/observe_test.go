@@ -0,0 +1,42 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func TestObservingProvider(t *testing.T) {
+	ctx := context.Background()
+	var changes []Change[testInvoice, int64]
+	errRejected := errors.New("rejected")
+	p := NewObservingProvider(NewProvider[testInvoice](testDB), func(_ context.Context, ch Change[testInvoice, int64]) error {
+		if ch.After != nil && ch.After.Amount < 0 {
+			return errRejected
+		}
+		changes = append(changes, ch)
+		return nil
+	})
+	assert.Equal(t, nil, p.Migrate())
+
+	v := &testInvoice{Number: "OBS-1", Amount: 1}
+	assert.Equal(t, nil, p.Insert(ctx, v))
+	_, err := p.UpdateFields(ctx, v.ID, map[string]any{"amount": 2})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, errRejected, p.Update(ctx, v.ID, &testInvoice{Amount: -1}))
+	assert.Equal(t, nil, p.Delete(ctx, v.ID))
+
+	assert.Equal(t, 3, len(changes))
+	assert.Equal(t, Change[testInvoice, int64]{Type: EventCreated, Key: v.ID, After: v}, changes[0])
+	// updates are observed whole, not only the fields written
+	assert.Equal(t, EventUpdated, changes[1].Type)
+	assert.Equal(t, 1, changes[1].Before.Amount)
+	assert.Equal(t, 2, changes[1].After.Amount)
+	assert.Equal(t, "OBS-1", changes[1].After.Number)
+	// the rejected update was rolled back
+	assert.Equal(t, EventDeleted, changes[2].Type)
+	assert.Equal(t, 2, changes[2].Before.Amount)
+	assert.Equal(t, (*testInvoice)(nil), changes[2].After)
+}
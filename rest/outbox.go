@@ -0,0 +1,89 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/ospiper/ginx/dbx"
+)
+
+// OutboxMessage is a change written to the outbox in the transaction of the change itself,
+// waiting for a Dispatcher to hand it to the webhooks.
+type OutboxMessage struct {
+	dbx.Deletable
+	Resource string `json:"resource" gorm:"index"`
+	Event    string `json:"event"`
+	// Body is the WebhookMessage posted to the webhooks, as JSON.
+	Body string `json:"body" gorm:"type:text"`
+	// DispatchedAt is set once the deliveries of the message are queued.
+	DispatchedAt *time.Time `json:"dispatched_at" gorm:"index"`
+}
+
+func (OutboxMessage) NewWithID(id int64) OutboxMessage {
+	return OutboxMessage{Deletable: dbx.Deletable{ID: id}}
+}
+
+// WebhookMessage is the body posted to webhooks for a change to a record.
+type WebhookMessage struct {
+	Resource string    `json:"resource"`
+	Event    string    `json:"event"`
+	Key      any       `json:"key"`
+	Payload  any       `json:"payload"`
+	Time     time.Time `json:"time"`
+}
+
+// OutboxProvider is OutboxProviderOf for models keyed by int64.
+type OutboxProvider[T dbx.ModelStruct[T]] = OutboxProviderOf[T, int64]
+
+// OutboxProviderOf decorates a provider to write an OutboxMessage for every record it inserts,
+// updates and deletes, in the same transaction as the change: a message is stored if and only
+// if its change is. Messages carry every field of the records. Records linked by AppendAssoc
+// and friends are not written to the outbox.
+type OutboxProviderOf[T dbx.ModelStructOf[T, ID], ID comparable] struct {
+	ObservingProviderOf[T, ID]
+	// Resource names the records in the messages.
+	Resource string
+}
+
+func NewOutboxProvider[T dbx.ModelStruct[T]](p Provider[T], resource string) *OutboxProvider[T] {
+	return NewOutboxProviderOf(p, resource)
+}
+
+// NewOutboxProviderOf writes the changes made through p to the outbox as changes of resource.
+func NewOutboxProviderOf[T dbx.ModelStructOf[T, ID], ID comparable](p ProviderOf[T, ID], resource string) *OutboxProviderOf[T, ID] {
+	op := &OutboxProviderOf[T, ID]{Resource: resource}
+	op.ObservingProviderOf = *NewObservingProviderOf(p, op.enqueue)
+	return op
+}
+
+// Migrate migrates the outbox along with T.
+func (p *OutboxProviderOf[T, ID]) Migrate() error {
+	if err := p.ProviderOf.Migrate(); err != nil {
+		return err
+	}
+	return p.GetDB().AutoMigrate(&OutboxMessage{})
+}
+
+// enqueue writes ch to the outbox, with the record after it or before it for deletions.
+func (p *OutboxProviderOf[T, ID]) enqueue(ctx context.Context, ch Change[T, ID]) error {
+	v := ch.After
+	if v == nil {
+		v = ch.Before
+	}
+	body, err := json.Marshal(WebhookMessage{
+		Resource: p.Resource,
+		Event:    ch.Type,
+		Key:      ch.Key,
+		Payload:  v,
+		Time:     time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	return dbx.Conn(ctx, p.GetDB()).Create(&OutboxMessage{
+		Resource: p.Resource,
+		Event:    ch.Type,
+		Body:     string(body),
+	}).Error
+}
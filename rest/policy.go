@@ -0,0 +1,56 @@
+package rest
+
+import (
+	"context"
+)
+
+// Policy authorizes the routes of a ResourceController. Rows outside Scope are invisible
+// to every route (404), a denied Can* check answers with 403.
+type Policy[T any] interface {
+	CanList(ctx context.Context) bool
+	CanRead(ctx context.Context, v *T) bool
+	CanCreate(ctx context.Context, v *T) bool
+	CanUpdate(ctx context.Context, v *T) bool
+	CanDelete(ctx context.Context, v *T) bool
+	// Scope returns the filters restricting the rows the caller is entitled to.
+	Scope(ctx context.Context) []FilterFunc
+}
+
+// OpenPolicy allows everything. Embed it to only override the checks you need.
+type OpenPolicy[T any] struct{}
+
+func (OpenPolicy[T]) CanList(context.Context) bool { return true }
+
+func (OpenPolicy[T]) CanRead(context.Context, *T) bool { return true }
+
+func (OpenPolicy[T]) CanCreate(context.Context, *T) bool { return true }
+
+func (OpenPolicy[T]) CanUpdate(context.Context, *T) bool { return true }
+
+func (OpenPolicy[T]) CanDelete(context.Context, *T) bool { return true }
+
+func (OpenPolicy[T]) Scope(context.Context) []FilterFunc { return nil }
+
+// ScopeContextKey is the key row scope filters are stored under.
+const ScopeContextKey = "ginx.rest.scope"
+
+// ContextWithScope returns a copy of ctx whose provider calls are restricted by filters,
+// in addition to any scope ctx already carries.
+func ContextWithScope(ctx context.Context, filters ...FilterFunc) context.Context {
+	if len(filters) == 0 {
+		return ctx
+	}
+	parent := ScopeFromContext(ctx)
+	scope := make([]FilterFunc, 0, len(parent)+len(filters))
+	scope = append(append(scope, parent...), filters...)
+	return context.WithValue(ctx, ScopeContextKey, scope)
+}
+
+// ScopeFromContext returns the scope filters carried by ctx.
+func ScopeFromContext(ctx context.Context) []FilterFunc {
+	if ctx == nil {
+		return nil
+	}
+	scope, _ := ctx.Value(ScopeContextKey).([]FilterFunc)
+	return scope
+}
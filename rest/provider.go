@@ -3,10 +3,17 @@ package rest
 import (
 	"context"
 	"errors"
+	"fmt"
+	"iter"
+	"net/http"
+	"reflect"
+	"strings"
 
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
 
+	"github.com/ospiper/ginx"
 	"github.com/ospiper/ginx/dbx"
 	"github.com/ospiper/ginx/util"
 )
@@ -15,34 +22,65 @@ const (
 	defaultBatchSize = 100
 )
 
-type Provider[T dbx.ModelStruct[T]] interface {
+// Provider is ProviderOf for models keyed by int64.
+type Provider[T dbx.ModelStruct[T]] = ProviderOf[T, int64]
+
+// ProviderOf provides the records of T, keyed by ID.
+type ProviderOf[T dbx.ModelStructOf[T, ID], ID comparable] interface {
 	GetDB() *gorm.DB
 	Model(ctx context.Context) *gorm.DB
 	Migrate() error
 
-	FindOne(ctx context.Context, id int64) (*T, error)
+	FindOne(ctx context.Context, id ID) (*T, error)
 	Find(ctx context.Context, conditions *FindConditions) ([]*T, error)
 	FindFirst(ctx context.Context, conditions *FindConditions) (*T, error)
+	// FindInBatches calls fn with the records matching conditions, batchSize at a time,
+	// ignoring the pagination of conditions.
+	FindInBatches(ctx context.Context, conditions *FindConditions, batchSize int, fn func([]*T) error) error
+	// Iterate yields the records matching conditions as they are read from the database rows.
+	// Records with preloads are loaded all at once before being yielded.
+	Iterate(ctx context.Context, conditions *FindConditions) iter.Seq2[*T, error]
 	FindAssoc(ctx context.Context, parentModel any, assocName string, conditions *FindConditions) ([]*T, error)
 	Count(ctx context.Context, filter []FilterFunc) (int64, error)
 	CountAssoc(ctx context.Context, parentModel any, assocName string, filter []FilterFunc) (int64, error)
+	// AppendAssoc links vs to the association of parentModel, creating the ones without a primary key.
+	AppendAssoc(ctx context.Context, parentModel any, assocName string, vs ...*T) error
+	// ReplaceAssoc links exactly vs to the association of parentModel.
+	ReplaceAssoc(ctx context.Context, parentModel any, assocName string, vs ...*T) error
+	// RemoveAssoc unlinks vs from the association of parentModel without deleting them.
+	RemoveAssoc(ctx context.Context, parentModel any, assocName string, vs ...*T) error
 
 	Insert(ctx context.Context, v *T) error
 	InsertMany(ctx context.Context, vs []*T) error
 	InsertBatch(ctx context.Context, vs []*T, batchSize int) error
 
-	Update(ctx context.Context, id int64, v *T) error
-	UpdateFields(ctx context.Context, id int64, fields map[string]any) (*T, error)
+	Update(ctx context.Context, id ID, v *T) error
+	UpdateFields(ctx context.Context, id ID, fields map[string]any) (*T, error)
+
+	Delete(ctx context.Context, id ID) error
+	// DeleteMany deletes every deletable record of ids and reports the outcome per id.
+	// The error is only set when the database fails.
+	DeleteMany(ctx context.Context, ids []ID) ([]DeleteResult[ID], error)
+}
 
-	Delete(ctx context.Context, id int64) error
-	DeleteMany(ctx context.Context, ids []int64) error
+// DeleteResult is the outcome of deleting one record in DeleteMany.
+type DeleteResult[ID comparable] struct {
+	ID      ID     `json:"id"`
+	Deleted bool   `json:"deleted"`
+	Error   string `json:"error,omitempty"`
 }
 
-type providerImpl[T dbx.ModelStruct[T]] struct {
+type providerImpl[T dbx.ModelStructOf[T, ID], ID comparable] struct {
 	db *gorm.DB
+	// tenantScoped reports whether T embeds dbx.TenantScoped
+	tenantScoped bool
 }
 
 func NewProvider[T dbx.ModelStruct[T]](db *gorm.DB) Provider[T] {
+	return NewProviderOf[T, int64](db)
+}
+
+func NewProviderOf[T dbx.ModelStructOf[T, ID], ID comparable](db *gorm.DB) ProviderOf[T, ID] {
 	// todo field check
 	// but how to use the sync map for parsing fields without interrupting gorm mechanics
 
@@ -56,7 +94,8 @@ func NewProvider[T dbx.ModelStruct[T]](db *gorm.DB) Provider[T] {
 	//for _, f := range sc.Fields {
 	//	fmt.Println(f.DBName)
 	//}
-	return &providerImpl[T]{db: db}
+	_, tenantScoped := util.As[dbx.WithTenant](new(T))
+	return &providerImpl[T, ID]{db: db, tenantScoped: tenantScoped}
 }
 
 type _assertion struct {
@@ -71,27 +110,200 @@ var (
 	// compile time type assertion
 	_ = NewProvider[_assertion](nil)
 
-	ErrNotFound     = errors.New("record not found")
-	ErrNotDeletable = errors.New("cannot delete record")
+	ErrNotFound     error = ginx.NewError(http.StatusNotFound, "not_found", "record not found")
+	ErrNotDeletable error = ginx.NewError(http.StatusConflict, "not_deletable", "cannot delete record")
+	ErrDuplicate    error = ginx.NewError(http.StatusConflict, "duplicate", "duplicate record")
 )
 
-func (w *providerImpl[T]) GetDB() *gorm.DB {
+func (w *providerImpl[T, ID]) GetDB() *gorm.DB {
 	return w.db
 }
 
-func (w *providerImpl[T]) Model(ctx context.Context) *gorm.DB {
+// conn returns the transaction carried by ctx, falling back to the provider's database.
+func (w *providerImpl[T, ID]) conn(ctx context.Context) *gorm.DB {
+	return dbx.Conn(ctx, w.db)
+}
+
+// tenant returns the tenant T is restricted to in ctx, if any.
+func (w *providerImpl[T, ID]) tenant(ctx context.Context) (string, bool) {
+	if !w.tenantScoped {
+		return "", false
+	}
+	return dbx.TenantFromContext(ctx)
+}
+
+// tenanted is conn restricted to the tenant carried by ctx.
+func (w *providerImpl[T, ID]) tenanted(ctx context.Context) *gorm.DB {
+	tx := w.conn(ctx)
+	if tenant, ok := w.tenant(ctx); ok {
+		tx = tx.Clauses(clause.Eq{
+			Column: clause.Column{Table: clause.CurrentTable, Name: "tenant_id"},
+			Value:  tenant,
+		})
+	}
+	return tx
+}
+
+// scoped is tenanted further restricted to the row scope carried by ctx, see ContextWithScope.
+func (w *providerImpl[T, ID]) scoped(ctx context.Context) (*gorm.DB, error) {
+	clauses, err := ApplyFilterFunc(ScopeFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return w.tenanted(ctx).Clauses(clauses...), nil
+}
+
+// stamp assigns the tenant carried by ctx to vs, overriding whatever the caller set.
+func (w *providerImpl[T, ID]) stamp(ctx context.Context, vs ...*T) {
+	tenant, ok := w.tenant(ctx)
+	if !ok {
+		return
+	}
+	for _, v := range vs {
+		if t, ok := util.As[dbx.WithTenant](v); ok {
+			t.SetTenantID(tenant)
+		}
+	}
+}
+
+// claim stamps vs with the tenant carried by ctx like stamp, failing with ErrNotFound if one of
+// them is a record of another tenant.
+func (w *providerImpl[T, ID]) claim(ctx context.Context, vs ...*T) error {
+	tenant, ok := w.tenant(ctx)
+	if !ok {
+		return nil
+	}
+	var zero ID
+	for _, v := range vs {
+		id, err := w.keyOf(ctx, v)
+		if err != nil {
+			return err
+		}
+		if id == zero {
+			continue
+		}
+		where, err := w.keyWhere(id)
+		if err != nil {
+			return err
+		}
+		var cnt int64
+		err = w.conn(ctx).Unscoped().Model(new(T)).
+			Where(where).
+			Where(clause.Neq{Column: clause.Column{Table: clause.CurrentTable, Name: "tenant_id"}, Value: tenant}).
+			Count(&cnt).
+			Error
+		if err != nil {
+			return err
+		}
+		if cnt > 0 {
+			return ErrNotFound
+		}
+	}
+	w.stamp(ctx, vs...)
+	return nil
+}
+
+// checkUnique returns ErrDuplicate if another row of the tenant shares one of the unique keys of v.
+// The record with id, unless it is the zero ID, is not considered another row.
+func (w *providerImpl[T, ID]) checkUnique(ctx context.Context, v *T, id ID) error {
+	u, ok := util.As[dbx.Uniquer](v)
+	if !ok {
+		return nil
+	}
+	sch, err := w.schema()
+	if err != nil {
+		return err
+	}
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	for _, key := range u.UniqueKeys() {
+		tx := w.tenanted(ctx).Model(new(T))
+		for _, col := range key {
+			f := sch.LookUpField(col)
+			if f == nil {
+				return fmt.Errorf("unique key: unknown field %s", col)
+			}
+			val, _ := f.ValueOf(ctx, rv)
+			tx = tx.Where(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: f.DBName}, Value: val})
+		}
+		var zero ID
+		if id != zero {
+			where, err := w.keyWhere(id)
+			if err != nil {
+				return err
+			}
+			tx = tx.Where(clause.Not(where))
+		}
+		var cnt int64
+		if err := tx.Count(&cnt).Error; err != nil {
+			return err
+		}
+		if cnt > 0 {
+			return fmt.Errorf("%w: %s", ErrDuplicate, strings.Join(key, ", "))
+		}
+	}
+	return nil
+}
+
+// schema returns the parsed gorm schema of T.
+func (w *providerImpl[T, ID]) schema() (*schema.Schema, error) {
+	return schemaOf[T](w.db)
+}
+
+// schemaOf returns the gorm schema of T, parsed with the settings of db.
+func schemaOf[T any](db *gorm.DB) (*schema.Schema, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(new(T)); err != nil {
+		return nil, err
+	}
+	return stmt.Schema, nil
+}
+
+// byID is scoped restricted to the record with id.
+func (w *providerImpl[T, ID]) byID(ctx context.Context, id ID) (*gorm.DB, error) {
+	where, err := w.keyWhere(id)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := w.scoped(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return tx.Where(where), nil
+}
+
+// omitted lists the columns updates must never write.
+func (w *providerImpl[T, ID]) omitted() ([]string, error) {
+	fields, err := w.keyFields()
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]string, 0, len(fields)+1)
+	for _, f := range fields {
+		ret = append(ret, f.field.DBName)
+	}
+	if w.tenantScoped {
+		ret = append(ret, "tenant_id")
+	}
+	return ret, nil
+}
+
+func (w *providerImpl[T, ID]) Model(ctx context.Context) *gorm.DB {
 	var m T
-	return w.db.WithContext(ctx).Model(&m)
+	return w.conn(ctx).Model(&m)
 }
 
-func (w *providerImpl[T]) Migrate() error {
+func (w *providerImpl[T, ID]) Migrate() error {
 	var m T
 	return w.db.AutoMigrate(&m)
 }
 
-func (w *providerImpl[T]) FindOne(ctx context.Context, id int64) (*T, error) {
+func (w *providerImpl[T, ID]) FindOne(ctx context.Context, id ID) (*T, error) {
 	ret := new(T)
-	tx := w.db.WithContext(ctx).Model(ret)
+	tx, err := w.byID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	tx = tx.Model(ret)
 	pld, ok := util.As[dbx.Preloader](ret)
 	if ok {
 		for _, c := range pld.Preloads() {
@@ -99,8 +311,8 @@ func (w *providerImpl[T]) FindOne(ctx context.Context, id int64) (*T, error) {
 		}
 	}
 
-	err := tx.
-		First(ret, id).
+	err = tx.
+		First(ret).
 		Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -111,11 +323,14 @@ func (w *providerImpl[T]) FindOne(ctx context.Context, id int64) (*T, error) {
 	return ret, nil
 }
 
-func (w *providerImpl[T]) Find(ctx context.Context, conditions *FindConditions) ([]*T, error) {
+func (w *providerImpl[T, ID]) Find(ctx context.Context, conditions *FindConditions) ([]*T, error) {
 	var res []*T
 	var m T
-	tx := w.db.WithContext(ctx)
-	tx, err := conditions.Apply(tx)
+	tx, err := w.scoped(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tx, err = conditions.Apply(tx)
 	if err != nil {
 		return nil, err
 	}
@@ -135,11 +350,14 @@ func (w *providerImpl[T]) Find(ctx context.Context, conditions *FindConditions)
 	return res, nil
 }
 
-func (w *providerImpl[T]) FindFirst(ctx context.Context, conditions *FindConditions) (*T, error) {
+func (w *providerImpl[T, ID]) FindFirst(ctx context.Context, conditions *FindConditions) (*T, error) {
 	var res []*T
 	var m T
-	tx := w.db.WithContext(ctx)
-	tx, err := conditions.Apply(tx)
+	tx, err := w.scoped(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tx, err = conditions.Apply(tx)
 	if err != nil {
 		return nil, err
 	}
@@ -162,33 +380,178 @@ func (w *providerImpl[T]) FindFirst(ctx context.Context, conditions *FindConditi
 	return res[0], nil
 }
 
-func (w *providerImpl[T]) FindAssoc(ctx context.Context, parentModel any, assocName string, conditions *FindConditions) ([]*T, error) {
+func (w *providerImpl[T, ID]) FindInBatches(ctx context.Context, conditions *FindConditions, batchSize int, fn func([]*T) error) error {
+	var m T
+	tx, err := w.scoped(ctx)
+	if err != nil {
+		return err
+	}
+	var cond FindConditions
+	if conditions != nil {
+		cond = *conditions
+	}
+	orders := cond.Orders
+	cond.Orders, cond.Pagination = nil, nil
+	tx, err = cond.Apply(tx)
+	if err != nil {
+		return err
+	}
+	pld, ok := util.As[dbx.Preloader](m)
+	if ok {
+		for _, c := range pld.Preloads() {
+			tx = tx.Preload(c)
+		}
+	}
+
+	if w.keyOrdered(orders) {
+		// keyset pagination on the primary key
+		var batch []*T
+		return tx.FindInBatches(&batch, batchSize, func(*gorm.DB, int) error {
+			return fn(batch)
+		}).Error
+	}
+	// other orders cannot resume after the last key, fall back to offsets
+	for _, order := range orders {
+		tx = order.Apply(tx)
+	}
+	if fields, err := w.keyFields(); err == nil {
+		// break ties so that pages do not overlap
+		for _, f := range fields {
+			tx = tx.Order(clause.OrderByColumn{Column: clause.Column{Table: clause.CurrentTable, Name: f.field.DBName}})
+		}
+	}
+	tx = tx.Session(&gorm.Session{})
+	for offset := 0; ; offset += batchSize {
+		var batch []*T
+		if err := tx.Offset(offset).Limit(batchSize).Find(&batch).Error; err != nil {
+			return err
+		}
+		if len(batch) > 0 {
+			if err := fn(batch); err != nil {
+				return err
+			}
+		}
+		if len(batch) < batchSize {
+			return nil
+		}
+	}
+}
+
+func (w *providerImpl[T, ID]) Iterate(ctx context.Context, conditions *FindConditions) iter.Seq2[*T, error] {
+	return func(yield func(*T, error) bool) {
+		var m T
+		_, preloaded := util.As[dbx.Preloader](m)
+		if preloaded || conditions != nil && len(conditions.Preloads) > 0 {
+			// preloads are queried for a slice of records, there is nothing to stream
+			vs, err := w.Find(ctx, conditions)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			for _, v := range vs {
+				if !yield(v, nil) {
+					return
+				}
+			}
+			return
+		}
+		tx, err := w.scoped(ctx)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		tx, err = conditions.Apply(tx.Model(&m))
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		rows, err := tx.Rows()
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		defer rows.Close()
+		for rows.Next() {
+			v := new(T)
+			if err := w.conn(ctx).ScanRows(rows, v); err != nil {
+				yield(nil, err)
+				return
+			}
+			if !yield(v, nil) {
+				return
+			}
+		}
+		if err := rows.Err(); err != nil {
+			yield(nil, err)
+		}
+	}
+}
+
+// keyOrdered reports whether orders sort by nothing but the ascending single primary key.
+func (w *providerImpl[T, ID]) keyOrdered(orders []Order) bool {
+	fields, err := w.keyFields()
+	if err != nil || len(fields) != 1 || len(orders) > 1 {
+		return false
+	}
+	return len(orders) == 0 || !orders[0].Desc && orders[0].Column == fields[0].field.DBName
+}
+
+// assoc is scoped restricted to the records of T associated to parentModel through assocName,
+// joining the join table of many2many associations.
+func (w *providerImpl[T, ID]) assoc(ctx context.Context, parentModel any, assocName string) (*gorm.DB, error) {
+	rel, err := relationOf(w.db, parentModel, assocName)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := w.scoped(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var m T
+	tx = tx.Model(&m)
+	conds := rel.ToQueryConditions(ctx, reflect.Indirect(reflect.ValueOf(parentModel)))
+	if rel.JoinTable == nil {
+		return tx.Clauses(clause.Where{Exprs: conds}), nil
+	}
+	return tx.Session(&gorm.Session{QueryFields: true}).Clauses(clause.From{Joins: []clause.Join{{
+		Table: clause.Table{Name: rel.JoinTable.Table},
+		ON:    clause.Where{Exprs: conds},
+	}}}), nil
+}
+
+func (w *providerImpl[T, ID]) FindAssoc(ctx context.Context, parentModel any, assocName string, conditions *FindConditions) ([]*T, error) {
 	var res []*T
 	var m T
-	tx := w.db.WithContext(ctx).Model(parentModel)
-	tx, err := conditions.Apply(tx)
+	tx, err := w.assoc(ctx, parentModel, assocName)
+	if err != nil {
+		return nil, err
+	}
+	tx, err = conditions.Apply(tx)
 	if err != nil {
 		return nil, err
 	}
 	pld, ok := util.As[dbx.Preloader](m)
 	if ok {
-		//fmt.Println("preload", pld.Preloads())
 		for _, c := range pld.Preloads() {
 			tx = tx.Preload(c)
 		}
 	}
 
-	err = tx.Association(assocName).Find(&res)
+	err = tx.Find(&res).Error
 	if err != nil {
 		return nil, err
 	}
 	return res, nil
 }
 
-func (w *providerImpl[T]) Count(ctx context.Context, filters []FilterFunc) (int64, error) {
+func (w *providerImpl[T, ID]) Count(ctx context.Context, filters []FilterFunc) (int64, error) {
 	var m T
 	var cnt int64
-	tx := w.db.WithContext(ctx).Model(&m)
+	tx, err := w.scoped(ctx)
+	if err != nil {
+		return 0, err
+	}
+	tx = tx.Model(&m)
 	clauses, err := ApplyFilterFunc(filters)
 	if err != nil {
 		return 0, err
@@ -202,38 +565,101 @@ func (w *providerImpl[T]) Count(ctx context.Context, filters []FilterFunc) (int6
 	return cnt, nil
 }
 
-func (w *providerImpl[T]) CountAssoc(ctx context.Context, parentModel any, assocName string, filters []FilterFunc) (int64, error) {
-	tx := w.db.WithContext(ctx).Model(parentModel)
+func (w *providerImpl[T, ID]) CountAssoc(ctx context.Context, parentModel any, assocName string, filters []FilterFunc) (int64, error) {
+	var cnt int64
+	tx, err := w.assoc(ctx, parentModel, assocName)
+	if err != nil {
+		return 0, err
+	}
 	clauses, err := ApplyFilterFunc(filters)
 	if err != nil {
 		return 0, err
 	}
-	tx = tx.Clauses(clauses...)
-	assoc := tx.Association(assocName)
-	return assoc.Count(), assoc.Error
+	err = tx.Clauses(clauses...).
+		Count(&cnt).
+		Error
+	if err != nil {
+		return 0, err
+	}
+	return cnt, nil
+}
+
+func (w *providerImpl[T, ID]) AppendAssoc(ctx context.Context, parentModel any, assocName string, vs ...*T) error {
+	if err := w.claim(ctx, vs...); err != nil {
+		return err
+	}
+	return w.conn(ctx).Model(parentModel).
+		Association(assocName).
+		Append(vs)
+}
+
+func (w *providerImpl[T, ID]) ReplaceAssoc(ctx context.Context, parentModel any, assocName string, vs ...*T) error {
+	if err := w.claim(ctx, vs...); err != nil {
+		return err
+	}
+	return w.conn(ctx).Model(parentModel).
+		Association(assocName).
+		Replace(vs)
+}
+
+func (w *providerImpl[T, ID]) RemoveAssoc(ctx context.Context, parentModel any, assocName string, vs ...*T) error {
+	if err := w.claim(ctx, vs...); err != nil {
+		return err
+	}
+	return w.conn(ctx).Model(parentModel).
+		Association(assocName).
+		Delete(vs)
 }
 
-func (w *providerImpl[T]) Insert(ctx context.Context, v *T) error {
-	return w.db.WithContext(ctx).
+func (w *providerImpl[T, ID]) Insert(ctx context.Context, v *T) error {
+	w.stamp(ctx, v)
+	var none ID
+	if err := w.checkUnique(ctx, v, none); err != nil {
+		return err
+	}
+	return w.conn(ctx).
 		Create(v).
 		Error
 }
 
-func (w *providerImpl[T]) InsertMany(ctx context.Context, vs []*T) error {
+func (w *providerImpl[T, ID]) InsertMany(ctx context.Context, vs []*T) error {
 	return w.InsertBatch(ctx, vs, defaultBatchSize)
 }
 
-func (w *providerImpl[T]) InsertBatch(ctx context.Context, vs []*T, batchSize int) error {
-	return w.db.WithContext(ctx).
+func (w *providerImpl[T, ID]) InsertBatch(ctx context.Context, vs []*T, batchSize int) error {
+	w.stamp(ctx, vs...)
+	var none ID
+	for _, v := range vs {
+		if err := w.checkUnique(ctx, v, none); err != nil {
+			return err
+		}
+	}
+	return w.conn(ctx).
 		CreateInBatches(vs, batchSize).
 		Error
 }
 
-func (w *providerImpl[T]) Update(ctx context.Context, id int64, v *T) error {
-	err := w.db.WithContext(ctx).Model(v).
+func (w *providerImpl[T, ID]) Update(ctx context.Context, id ID, v *T) error {
+	if _, ok := w.tenant(ctx); ok {
+		// refuse to touch rows of other tenants rather than silently updating nothing
+		if _, err := w.FindOne(ctx, id); err != nil {
+			return err
+		}
+	}
+	if err := w.checkUnique(ctx, v, id); err != nil {
+		return err
+	}
+	omitted, err := w.omitted()
+	if err != nil {
+		return err
+	}
+	tx, err := w.byID(ctx, id)
+	if err != nil {
+		return err
+	}
+	err = tx.Model(v).
 		Clauses(clause.Returning{}).
-		Where("id = ?", id).
-		Omit("id").
+		Omit(omitted...).
 		Updates(v).
 		Limit(1).
 		Error
@@ -243,12 +669,24 @@ func (w *providerImpl[T]) Update(ctx context.Context, id int64, v *T) error {
 	return nil
 }
 
-func (w *providerImpl[T]) UpdateFields(ctx context.Context, id int64, fields map[string]any) (*T, error) {
+func (w *providerImpl[T, ID]) UpdateFields(ctx context.Context, id ID, fields map[string]any) (*T, error) {
 	var m T
-	err := w.db.WithContext(ctx).Model(&m).
+	if _, ok := w.tenant(ctx); ok {
+		if _, err := w.FindOne(ctx, id); err != nil {
+			return nil, err
+		}
+	}
+	omitted, err := w.omitted()
+	if err != nil {
+		return nil, err
+	}
+	tx, err := w.byID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Model(&m).
 		Clauses(clause.Returning{}).
-		Where("id = ?", id).
-		Omit("id").
+		Omit(omitted...).
 		Updates(fields).
 		Limit(1).
 		Error
@@ -262,29 +700,115 @@ type WithDeletableCheck interface {
 	Deletable(ctx context.Context) bool
 }
 
+// WithDeleteCheck is WithDeletableCheck explaining why a record cannot be deleted.
+type WithDeleteCheck interface {
+	CheckDelete(ctx context.Context) error
+}
+
+// checkDeletable returns ErrNotDeletable, wrapping the reason if there is one, for records
+// which refuse to be deleted.
+func checkDeletable(ctx context.Context, v any) error {
+	if _, ok := util.As[dbx.PermanentModel](v); ok {
+		return fmt.Errorf("%w: %w", ErrNotDeletable, dbx.ErrPermanent)
+	}
+	if d, ok := v.(WithDeleteCheck); ok {
+		if err := d.CheckDelete(ctx); err != nil {
+			return fmt.Errorf("%w: %w", ErrNotDeletable, err)
+		}
+	}
+	if d, ok := v.(WithDeletableCheck); ok {
+		if !d.Deletable(ctx) {
+			return ErrNotDeletable
+		}
+	}
+	return nil
+}
+
 // Delete if it's soft delete how to locate and restore it on insert conflict?
-func (w *providerImpl[T]) Delete(ctx context.Context, id int64) error {
+func (w *providerImpl[T, ID]) Delete(ctx context.Context, id ID) error {
 	var m T
 	res, err := w.FindOne(ctx, id)
 	if err != nil {
 		return err
 	}
-	var deleter any = res
-	if d, ok := deleter.(WithDeletableCheck); ok {
-		if !d.Deletable(ctx) {
-			return ErrNotDeletable
-		}
+	if err = checkDeletable(ctx, res); err != nil {
+		return err
 	}
-	return w.db.WithContext(ctx).
-		Delete(&m, id).
+	tx, err := w.byID(ctx, id)
+	if err != nil {
+		return err
+	}
+	return tx.
+		Delete(&m).
 		Limit(1).
 		Error
 }
 
-func (w *providerImpl[T]) DeleteMany(ctx context.Context, ids []int64) error {
+func (w *providerImpl[T, ID]) DeleteMany(ctx context.Context, ids []ID) ([]DeleteResult[ID], error) {
 	var m T
-	return w.db.WithContext(ctx).
-		Delete(&m, ids).
-		Limit(len(ids)).
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	where, err := w.keyWhere(ids...)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := w.scoped(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var found []*T
+	err = tx.Where(where).Find(&found).Error
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[ID]*T, len(found))
+	for _, v := range found {
+		id, err := w.keyOf(ctx, v)
+		if err != nil {
+			return nil, err
+		}
+		byID[id] = v
+	}
+
+	results := make([]DeleteResult[ID], len(ids))
+	deletable := make([]ID, 0, len(ids))
+	for i, id := range ids {
+		results[i].ID = id
+		v, ok := byID[id]
+		if !ok {
+			results[i].Error = ErrNotFound.Error()
+			continue
+		}
+		if err := checkDeletable(ctx, v); err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		deletable = append(deletable, id)
+	}
+	if len(deletable) == 0 {
+		return results, nil
+	}
+	where, err = w.keyWhere(deletable...)
+	if err != nil {
+		return nil, err
+	}
+	tx, err = w.scoped(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.
+		Where(where).
+		Delete(&m).
+		Limit(len(deletable)).
 		Error
+	if err != nil {
+		return nil, err
+	}
+	for i := range results {
+		if results[i].Error == "" {
+			results[i].Deleted = true
+		}
+	}
+	return results, nil
 }
@@ -1,12 +1,17 @@
 package rest
 
 import (
+	"context"
+	"errors"
 	"log"
 	"os"
 	"testing"
 
+	"github.com/go-playground/assert/v2"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+
+	"github.com/ospiper/ginx/dbx"
 )
 
 var testDB *gorm.DB
@@ -20,3 +25,253 @@ func TestMain(m *testing.M) {
 	code := m.Run()
 	os.Exit(code)
 }
+
+type testOrder struct {
+	dbx.Model
+	Title string `json:"title"`
+}
+
+func (testOrder) NewWithID(id int64) testOrder {
+	return testOrder{Model: dbx.Model{ID: id}}
+}
+
+type testOrderItem struct {
+	dbx.Model
+	OrderID int64  `json:"order_id"`
+	Name    string `json:"name"`
+}
+
+func (testOrderItem) NewWithID(id int64) testOrderItem {
+	return testOrderItem{Model: dbx.Model{ID: id}}
+}
+
+func TestTransaction(t *testing.T) {
+	orders := NewProvider[testOrder](testDB)
+	items := NewProvider[testOrderItem](testDB)
+	assert.Equal(t, nil, orders.Migrate())
+	assert.Equal(t, nil, items.Migrate())
+	ctx := context.Background()
+	errAbort := errors.New("abort")
+
+	err := dbx.Transaction(ctx, testDB, func(ctx context.Context) error {
+		o := &testOrder{Title: "rolled back"}
+		if err := orders.Insert(ctx, o); err != nil {
+			return err
+		}
+		if err := items.Insert(ctx, &testOrderItem{OrderID: o.ID, Name: "item"}); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	assert.Equal(t, errAbort, err)
+	cnt, err := orders.Count(ctx, []FilterFunc{Eq("title", "rolled back")})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(0), cnt)
+
+	err = dbx.Transaction(ctx, testDB, func(ctx context.Context) error {
+		o := &testOrder{Title: "committed"}
+		if err := orders.Insert(ctx, o); err != nil {
+			return err
+		}
+		nested := dbx.Transaction(ctx, testDB, func(ctx context.Context) error {
+			if err := items.Insert(ctx, &testOrderItem{OrderID: o.ID, Name: "savepoint"}); err != nil {
+				return err
+			}
+			return errAbort
+		})
+		assert.Equal(t, errAbort, nested)
+		return items.Insert(ctx, &testOrderItem{OrderID: o.ID, Name: "kept"})
+	})
+	assert.Equal(t, nil, err)
+	cnt, err = orders.Count(ctx, []FilterFunc{Eq("title", "committed")})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(1), cnt)
+	cnt, err = items.Count(ctx, []FilterFunc{Eq("name", "savepoint")})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(0), cnt)
+	cnt, err = items.Count(ctx, []FilterFunc{Eq("name", "kept")})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(1), cnt)
+}
+
+type testCustomer struct {
+	dbx.Model
+	dbx.TenantScoped
+	Email string `json:"email"`
+}
+
+func (testCustomer) NewWithID(id int64) testCustomer {
+	return testCustomer{Model: dbx.Model{ID: id}}
+}
+
+func (testCustomer) UniqueKeys() [][]string {
+	return [][]string{{"email"}}
+}
+
+type testAccount struct {
+	dbx.Model
+	Customers []*testCustomer `json:"customers" gorm:"many2many:test_account_customers"`
+}
+
+func TestTenantScoped(t *testing.T) {
+	customers := NewProvider[testCustomer](testDB)
+	assert.Equal(t, nil, customers.Migrate())
+	acme := dbx.ContextWithTenant(context.Background(), "acme")
+	globex := dbx.ContextWithTenant(context.Background(), "globex")
+
+	a := &testCustomer{Email: "a@example.com", TenantScoped: dbx.TenantScoped{TenantID: "globex"}}
+	assert.Equal(t, nil, customers.Insert(acme, a))
+	assert.Equal(t, "acme", a.TenantID)
+	assert.Equal(t, nil, customers.Insert(globex, &testCustomer{Email: "a@example.com"}))
+	err := customers.Insert(acme, &testCustomer{Email: "a@example.com"})
+	assert.Equal(t, true, errors.Is(err, ErrDuplicate))
+
+	_, err = customers.FindOne(globex, a.ID)
+	assert.Equal(t, ErrNotFound, err)
+	cnt, err := customers.Count(acme, nil)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(1), cnt)
+	assert.Equal(t, ErrNotFound, customers.Update(globex, a.ID, &testCustomer{Email: "b@example.com"}))
+	assert.Equal(t, ErrNotFound, customers.Delete(globex, a.ID))
+	_, err = customers.FindOne(acme, a.ID)
+	assert.Equal(t, nil, err)
+
+	// associations are stamped and cannot reach records of other tenants
+	assert.Equal(t, nil, testDB.AutoMigrate(&testAccount{}))
+	account := &testAccount{}
+	assert.Equal(t, nil, testDB.Create(account).Error)
+	c := &testCustomer{Email: "c@example.com"}
+	assert.Equal(t, nil, customers.AppendAssoc(acme, account, "Customers", c, a))
+	assert.Equal(t, "acme", c.TenantID)
+	g, err := customers.FindFirst(globex, nil)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, ErrNotFound, customers.AppendAssoc(acme, account, "Customers", g))
+	assert.Equal(t, ErrNotFound, customers.ReplaceAssoc(acme, account, "Customers", g))
+	linked, err := customers.FindAssoc(acme, account, "Customers", nil)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, len(linked))
+	cnt, err = customers.CountAssoc(globex, account, "Customers", nil)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(0), cnt)
+}
+
+type testTag struct {
+	dbx.Model
+	Name string `json:"name"`
+}
+
+func (testTag) NewWithID(id int64) testTag {
+	return testTag{Model: dbx.Model{ID: id}}
+}
+
+func (t *testTag) CheckDelete(context.Context) error {
+	if t.Name == "locked" {
+		return errors.New("tag is locked")
+	}
+	return nil
+}
+
+func TestDeleteMany(t *testing.T) {
+	tags := NewProvider[testTag](testDB)
+	assert.Equal(t, nil, tags.Migrate())
+	ctx := context.Background()
+	free, locked := &testTag{Name: "free"}, &testTag{Name: "locked"}
+	assert.Equal(t, nil, tags.InsertMany(ctx, []*testTag{free, locked}))
+
+	err := tags.Delete(ctx, locked.ID)
+	assert.Equal(t, true, errors.Is(err, ErrNotDeletable))
+	assert.Equal(t, "cannot delete record: tag is locked", err.Error())
+
+	results, err := tags.DeleteMany(ctx, []int64{free.ID, locked.ID, 999999})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []DeleteResult[int64]{
+		{ID: free.ID, Deleted: true},
+		{ID: locked.ID, Error: "cannot delete record: tag is locked"},
+		{ID: 999999, Error: ErrNotFound.Error()},
+	}, results)
+	_, err = tags.FindOne(ctx, free.ID)
+	assert.Equal(t, ErrNotFound, err)
+}
+
+type testReading struct {
+	dbx.Model
+	Sensor string `json:"sensor"`
+	Value  int    `json:"value" access:"read=admin"`
+}
+
+func (testReading) NewWithID(id int64) testReading {
+	return testReading{Model: dbx.Model{ID: id}}
+}
+
+func TestFindInBatches(t *testing.T) {
+	readings := NewProvider[testReading](testDB)
+	assert.Equal(t, nil, readings.Migrate())
+	ctx := context.Background()
+	var vs []*testReading
+	for i := range 5 {
+		vs = append(vs, &testReading{Sensor: "batch", Value: i % 3})
+	}
+	assert.Equal(t, nil, readings.InsertMany(ctx, vs))
+	batch := &FindConditions{
+		Filters:    []FilterFunc{Eq("sensor", "batch")},
+		Pagination: &Range{Start: 0, End: 0},
+	}
+
+	var sizes, values []int
+	err := readings.FindInBatches(ctx, batch, 2, func(vs []*testReading) error {
+		sizes = append(sizes, len(vs))
+		for _, v := range vs {
+			values = append(values, v.Value)
+		}
+		return nil
+	})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []int{2, 2, 1}, sizes)
+	assert.Equal(t, []int{0, 1, 2, 0, 1}, values)
+
+	batch.Orders = []Order{{Column: "value", Desc: true}}
+	values = nil
+	err = readings.FindInBatches(ctx, batch, 2, func(vs []*testReading) error {
+		for _, v := range vs {
+			values = append(values, v.Value)
+		}
+		return nil
+	})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []int{2, 1, 1, 0, 0}, values)
+
+	errStop := errors.New("stop")
+	err = readings.FindInBatches(ctx, batch, 2, func([]*testReading) error { return errStop })
+	assert.Equal(t, errStop, err)
+}
+
+func TestIterate(t *testing.T) {
+	readings := NewProvider[testReading](testDB)
+	assert.Equal(t, nil, readings.Migrate())
+	ctx := context.Background()
+	assert.Equal(t, nil, readings.InsertMany(ctx, []*testReading{
+		{Sensor: "iterate", Value: 1},
+		{Sensor: "iterate", Value: 2},
+		{Sensor: "iterate", Value: 3},
+	}))
+	cond := &FindConditions{
+		Filters:    []FilterFunc{Eq("sensor", "iterate")},
+		Orders:     []Order{{Column: "value", Desc: true}},
+		Pagination: &Range{Start: 0, End: 1},
+	}
+	var values []int
+	for v, err := range readings.Iterate(ctx, cond) {
+		assert.Equal(t, nil, err)
+		values = append(values, v.Value)
+	}
+	assert.Equal(t, []int{3, 2}, values)
+
+	cond.Pagination = nil
+	values = nil
+	for v, err := range readings.Iterate(ctx, cond) {
+		assert.Equal(t, nil, err)
+		values = append(values, v.Value)
+		break
+	}
+	assert.Equal(t, []int{3}, values)
+}
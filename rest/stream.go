@@ -0,0 +1,81 @@
+package rest
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+)
+
+// streamFlushEvery is the number of records written between flushes of a streamed list.
+const streamFlushEvery = 100
+
+// streamList writes the records matching cond as a JSON array, encoding them one at a time as
+// Provider.Iterate reads them. It stops reading as soon as the client goes away.
+func (r *ResourceControllerOf[T, ID]) streamList(ctx context.Context, c *gin.Context, cond *FindConditions) {
+	cnt, err := r.Provider.Count(ctx, cond.Filters)
+	if err != nil {
+		writer(c).Error(c, err)
+		return
+	}
+	code, hd := PaginationHeader(cond.Pagination, cnt)
+	hidden := r.guard().hidden(ctx)
+	started := false
+	start := func() error {
+		started = true
+		if hd != "" {
+			c.Header("Content-Range", hd)
+		}
+		c.Header("Content-Type", "application/json; charset=utf-8")
+		c.Status(code)
+		_, err := c.Writer.WriteString("[")
+		return err
+	}
+	n := 0
+	err = func() error {
+		for v, err := range r.Provider.Iterate(ctx, cond) {
+			if err != nil {
+				return err
+			}
+			b, err := encodeVisible(v, hidden)
+			if err != nil {
+				return err
+			}
+			sep := ","
+			if !started {
+				if err := start(); err != nil {
+					return err
+				}
+				sep = ""
+			}
+			if _, err := c.Writer.WriteString(sep); err != nil {
+				return err
+			}
+			if _, err := c.Writer.Write(b); err != nil {
+				return err
+			}
+			if n++; n%streamFlushEvery == 0 {
+				c.Writer.Flush()
+			}
+			if err := c.Request.Context().Err(); err != nil {
+				return err
+			}
+		}
+		if !started {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		_, err := c.Writer.WriteString("]")
+		return err
+	}()
+	if err == nil {
+		return
+	}
+	if !started {
+		writer(c).Error(c, err)
+		return
+	}
+	// the status is sent already, the client sees a truncated array
+	_ = c.Error(err)
+	c.Abort()
+}
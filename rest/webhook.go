@@ -0,0 +1,397 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"github.com/ospiper/ginx"
+	"github.com/ospiper/ginx/dbx"
+)
+
+// Statuses of WebhookDelivery.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	// DeliveryDead is a delivery given up after Dispatcher.MaxAttempts.
+	DeliveryDead = "dead"
+)
+
+// Headers of webhook requests. Redeliveries of a message to an endpoint have the same id.
+const (
+	WebhookIDHeader        = "X-Webhook-Id"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookSignatureHeader = "X-Webhook-Signature"
+)
+
+// WebhookEndpoint is a URL the messages of the outbox are posted to.
+type WebhookEndpoint struct {
+	URL string
+	// Secret signs the requests, see WebhookSignature.
+	Secret string
+	// Resources and Events restrict the messages posted, all of them when empty.
+	Resources []string
+	Events    []string
+}
+
+func (e WebhookEndpoint) accepts(m *OutboxMessage) bool {
+	return (len(e.Resources) == 0 || slices.Contains(e.Resources, m.Resource)) &&
+		(len(e.Events) == 0 || slices.Contains(e.Events, m.Event))
+}
+
+// WebhookDelivery is the delivery of an outbox message to an endpoint, and its log.
+type WebhookDelivery struct {
+	dbx.Permanent
+	MessageID int64  `json:"message_id" gorm:"index"`
+	Endpoint  string `json:"endpoint"`
+	Resource  string `json:"resource"`
+	Event     string `json:"event"`
+	Body      string `json:"body" gorm:"type:text"`
+	Status    string `json:"status" gorm:"index"`
+	Attempts  int    `json:"attempts"`
+	// LastStatusCode and LastError describe the outcome of the last attempt.
+	LastStatusCode int        `json:"last_status_code"`
+	LastError      string     `json:"last_error"`
+	NextAttemptAt  time.Time  `json:"next_attempt_at" gorm:"index"`
+	DeliveredAt    *time.Time `json:"delivered_at"`
+	// LockedUntil reserves the delivery to the dispatcher attempting it.
+	LockedUntil *time.Time `json:"-"`
+}
+
+func (WebhookDelivery) NewWithID(id int64) WebhookDelivery {
+	return WebhookDelivery{Permanent: dbx.Permanent{ID: id}}
+}
+
+// WebhookSignature signs the body of a webhook request sent at timestamp, in unix seconds:
+// the hex HMAC-SHA256 with secret of the timestamp, a dot and the body, prefixed with sha256=.
+func WebhookSignature(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Dispatcher posts the messages of the outbox to the webhook endpoints. It queues a delivery per
+// message and accepting endpoint, then attempts the due deliveries until they are answered with
+// a 2xx status, backing off exponentially, and gives them up as dead after MaxAttempts.
+// Deliveries are at least once: receivers deduplicate them with WebhookIDHeader.
+//
+// Several dispatchers may share a database, each delivery is attempted by one at a time.
+type Dispatcher struct {
+	DB        *gorm.DB
+	Endpoints []WebhookEndpoint
+	Client    *http.Client
+	// Interval is the time Run waits between passes.
+	Interval  time.Duration
+	BatchSize int
+	// MaxAttempts is the number of attempts of a delivery before it is dead.
+	MaxAttempts int
+	// Backoff is the delay before the second attempt, doubled after every further attempt
+	// up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// NewDispatcher returns a dispatcher of the outbox of db to endpoints, polling every second.
+func NewDispatcher(db *gorm.DB, endpoints ...WebhookEndpoint) *Dispatcher {
+	return &Dispatcher{
+		DB:          db,
+		Endpoints:   endpoints,
+		Client:      &http.Client{Timeout: 10 * time.Second},
+		Interval:    time.Second,
+		BatchSize:   defaultBatchSize,
+		MaxAttempts: 8,
+		Backoff:     time.Second,
+		MaxBackoff:  time.Hour,
+	}
+}
+
+// Migrate migrates the outbox and the deliveries.
+func (d *Dispatcher) Migrate() error {
+	return d.DB.AutoMigrate(&OutboxMessage{}, &WebhookDelivery{})
+}
+
+// Run dispatches until ctx is cancelled, see ginx.Worker. Failing passes are retried at the
+// next interval.
+func (d *Dispatcher) Run(ctx context.Context) error {
+	ticker := time.NewTicker(d.Interval)
+	defer ticker.Stop()
+	for {
+		if err := d.Dispatch(ctx); err != nil && ctx.Err() == nil {
+			logrus.WithContext(ctx).WithError(err).Error("webhook dispatch failed")
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Dispatch queues the deliveries of the pending outbox messages and attempts the due ones,
+// BatchSize of each at most.
+func (d *Dispatcher) Dispatch(ctx context.Context) error {
+	if err := d.queue(ctx); err != nil {
+		return err
+	}
+	return d.deliver(ctx)
+}
+
+// queue moves the pending messages of the outbox to deliveries.
+func (d *Dispatcher) queue(ctx context.Context) error {
+	var msgs []*OutboxMessage
+	err := d.DB.WithContext(ctx).
+		Where("dispatched_at IS NULL").
+		Order("id").
+		Limit(d.BatchSize).
+		Find(&msgs).Error
+	if err != nil {
+		return err
+	}
+	for _, m := range msgs {
+		err = dbx.Transaction(ctx, d.DB, func(ctx context.Context) error {
+			tx := dbx.Conn(ctx, d.DB)
+			now := time.Now()
+			// claim the message, another dispatcher may have queued it already
+			res := tx.Model(m).Where("dispatched_at IS NULL").Update("dispatched_at", now)
+			if res.Error != nil || res.RowsAffected == 0 {
+				return res.Error
+			}
+			var deliveries []*WebhookDelivery
+			for _, e := range d.Endpoints {
+				if e.accepts(m) {
+					deliveries = append(deliveries, &WebhookDelivery{
+						MessageID:     m.ID,
+						Endpoint:      e.URL,
+						Resource:      m.Resource,
+						Event:         m.Event,
+						Body:          m.Body,
+						Status:        DeliveryPending,
+						NextAttemptAt: now,
+					})
+				}
+			}
+			if len(deliveries) == 0 {
+				return nil
+			}
+			return tx.Create(deliveries).Error
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deliver attempts the due deliveries.
+func (d *Dispatcher) deliver(ctx context.Context) error {
+	now := time.Now()
+	var due []*WebhookDelivery
+	err := d.DB.WithContext(ctx).
+		Where("status = ? AND next_attempt_at <= ?", DeliveryPending, now).
+		Where("locked_until IS NULL OR locked_until < ?", now).
+		Order("next_attempt_at").
+		Limit(d.BatchSize).
+		Find(&due).Error
+	if err != nil {
+		return err
+	}
+	for _, dl := range due {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		ok, err := d.claim(ctx, dl)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		if err := d.attempt(ctx, dl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// claim reserves dl for longer than an attempt may take, unless another dispatcher holds it or
+// it is no longer due since it was read, e.g. because another dispatcher delivered it meanwhile.
+func (d *Dispatcher) claim(ctx context.Context, dl *WebhookDelivery) (bool, error) {
+	at := time.Now()
+	res := d.DB.WithContext(ctx).Model(dl).
+		Where("status = ? AND next_attempt_at <= ?", DeliveryPending, at).
+		Where("locked_until IS NULL OR locked_until < ?", at).
+		Update("locked_until", at.Add(2*d.timeout()))
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+func (d *Dispatcher) timeout() time.Duration {
+	if d.Client != nil && d.Client.Timeout > 0 {
+		return d.Client.Timeout
+	}
+	return time.Minute
+}
+
+// attempt posts dl to its endpoint and records the outcome.
+func (d *Dispatcher) attempt(ctx context.Context, dl *WebhookDelivery) error {
+	endpoint, ok := d.endpoint(dl.Endpoint)
+	var code int
+	var err error
+	if !ok {
+		err = errors.New("endpoint is not registered")
+	} else {
+		code, err = d.post(ctx, endpoint, dl)
+	}
+	if ctx.Err() != nil {
+		// shutting down, the attempt does not count
+		return ctx.Err()
+	}
+	now := time.Now()
+	dl.Attempts++
+	dl.LastStatusCode = code
+	dl.LastError = ""
+	dl.LockedUntil = nil
+	switch {
+	case err == nil:
+		dl.Status = DeliveryDelivered
+		dl.DeliveredAt = &now
+	case dl.Attempts >= d.MaxAttempts:
+		dl.LastError = err.Error()
+		dl.Status = DeliveryDead
+	default:
+		dl.LastError = err.Error()
+		dl.NextAttemptAt = now.Add(d.backoff(dl.Attempts))
+	}
+	return d.DB.WithContext(ctx).Model(dl).Select(
+		"status", "attempts", "last_status_code", "last_error", "next_attempt_at", "delivered_at", "locked_until",
+	).Updates(dl).Error
+}
+
+func (d *Dispatcher) endpoint(url string) (WebhookEndpoint, bool) {
+	for _, e := range d.Endpoints {
+		if e.URL == url {
+			return e, true
+		}
+	}
+	return WebhookEndpoint{}, false
+}
+
+// backoff returns the delay after the attempt numbered attempts.
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.Backoff
+	for i := 1; i < attempts && delay < d.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if d.MaxBackoff > 0 && delay > d.MaxBackoff {
+		delay = d.MaxBackoff
+	}
+	return delay
+}
+
+// post sends dl to e, failing unless it is answered with a 2xx status.
+func (d *Dispatcher) post(ctx context.Context, e WebhookEndpoint, dl *WebhookDelivery) (int, error) {
+	body := []byte(dl.Body)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	ts := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookIDHeader, strconv.FormatInt(dl.ID, 10))
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(ts, 10))
+	req.Header.Set(WebhookSignatureHeader, WebhookSignature(e.Secret, ts, body))
+	client := d.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 1<<16))
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return res.StatusCode, fmt.Errorf("endpoint answered %s", res.Status)
+	}
+	return res.StatusCode, nil
+}
+
+// deliveryLogPolicy makes the delivery log read only.
+type deliveryLogPolicy struct {
+	OpenPolicy[WebhookDelivery]
+}
+
+func (deliveryLogPolicy) CanCreate(context.Context, *WebhookDelivery) bool { return false }
+
+func (deliveryLogPolicy) CanUpdate(context.Context, *WebhookDelivery) bool { return false }
+
+// errDeliveryLocked answers retries of a delivery being attempted.
+var errDeliveryLocked error = ginx.NewError(http.StatusConflict, "delivery_locked", "delivery is being attempted")
+
+// RegisterDeliveryLog serves the deliveries on group as a read only resource, along with
+// POST /:id/retry queuing a delivery again, dead or not, for an immediate attempt. Deliveries
+// being attempted cannot be retried until the attempt is over.
+func (d *Dispatcher) RegisterDeliveryLog(group *gin.RouterGroup) *ResourceController[WebhookDelivery] {
+	ctrl := &ResourceController[WebhookDelivery]{
+		Name:     "deliveries",
+		Provider: NewProvider[WebhookDelivery](d.DB),
+		Group:    group,
+		Policy:   deliveryLogPolicy{},
+	}
+	ctrl.Register()
+	ctrl.handle(group, http.MethodPost, "/:id/retry", d.retry, "retry", ginx.Operation{
+		URI: keyURI[int64]("", "id"),
+	})
+	return ctrl
+}
+
+func (d *Dispatcher) retry(c *gin.Context) {
+	id, err := bindKey[int64](c, "", "id")
+	if err != nil {
+		writer(c).Error(c, ginx.BindError(err))
+		return
+	}
+	ctx := ginx.RequestContext(c)
+	now := time.Now()
+	res := d.DB.WithContext(ctx).Model(&WebhookDelivery{}).
+		Where("id = ?", id).
+		Where("locked_until IS NULL OR locked_until < ?", now).
+		Updates(map[string]any{
+			"status":          DeliveryPending,
+			"attempts":        0,
+			"next_attempt_at": now,
+		})
+	if res.Error != nil {
+		writer(c).Error(c, res.Error)
+		return
+	}
+	dl := new(WebhookDelivery)
+	if err := d.DB.WithContext(ctx).First(dl, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = ErrNotFound
+		}
+		writer(c).Error(c, err)
+		return
+	}
+	if res.RowsAffected == 0 {
+		writer(c).Error(c, errDeliveryLocked)
+		return
+	}
+	writer(c).Success(c, http.StatusOK, dl)
+}
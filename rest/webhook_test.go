@@ -0,0 +1,152 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/assert/v2"
+
+	"github.com/ospiper/ginx/dbx"
+)
+
+type testInvoice struct {
+	dbx.Model
+	Number string `json:"number"`
+	Amount int    `json:"amount"`
+}
+
+func (testInvoice) NewWithID(id int64) testInvoice {
+	return testInvoice{Model: dbx.Model{ID: id}}
+}
+
+// testReceiver answers webhooks with the statuses in order, then 204, checking their signature.
+type testReceiver struct {
+	mu       sync.Mutex
+	statuses []int
+	bodies   []string
+}
+
+func (rc *testReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	body, _ := io.ReadAll(r.Body)
+	ts, _ := strconv.ParseInt(r.Header.Get(WebhookTimestampHeader), 10, 64)
+	if r.Header.Get(WebhookSignatureHeader) != WebhookSignature("secret", ts, body) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	status := http.StatusNoContent
+	if len(rc.statuses) > 0 {
+		status, rc.statuses = rc.statuses[0], rc.statuses[1:]
+	}
+	if status < 300 {
+		rc.bodies = append(rc.bodies, string(body))
+	}
+	w.WriteHeader(status)
+}
+
+func TestDispatcher(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	invoices := NewOutboxProvider(NewProvider[testInvoice](testDB), "invoices")
+	assert.Equal(t, nil, invoices.Migrate())
+
+	flaky := &testReceiver{statuses: []int{http.StatusInternalServerError}}
+	flakySrv := httptest.NewServer(flaky)
+	defer flakySrv.Close()
+	down := &testReceiver{statuses: []int{http.StatusBadGateway, http.StatusBadGateway}}
+	downSrv := httptest.NewServer(down)
+	defer downSrv.Close()
+	d := NewDispatcher(testDB,
+		WebhookEndpoint{URL: flakySrv.URL, Secret: "secret"},
+		WebhookEndpoint{URL: downSrv.URL, Secret: "secret", Events: []string{EventDeleted}},
+	)
+	d.MaxAttempts = 2
+	d.Backoff = 0
+	assert.Equal(t, nil, d.Migrate())
+
+	v := &testInvoice{Number: "INV-1", Amount: 10}
+	assert.Equal(t, nil, invoices.Insert(ctx, v))
+	errRollback := errors.New("rollback")
+	err := dbx.Transaction(ctx, testDB, func(ctx context.Context) error {
+		assert.Equal(t, nil, invoices.Insert(ctx, &testInvoice{Number: "INV-2"}))
+		return errRollback
+	})
+	assert.Equal(t, errRollback, err)
+	assert.Equal(t, nil, invoices.Delete(ctx, v.ID))
+	var queued int64
+	assert.Equal(t, nil, testDB.Model(&OutboxMessage{}).Where("resource = ?", "invoices").Count(&queued).Error)
+	assert.Equal(t, int64(2), queued)
+
+	assert.Equal(t, nil, d.Dispatch(ctx))
+	assert.Equal(t, nil, d.Dispatch(ctx))
+	// nothing is left to do
+	assert.Equal(t, nil, d.Dispatch(ctx))
+
+	var deliveries []*WebhookDelivery
+	assert.Equal(t, nil, testDB.Order("id").Find(&deliveries).Error)
+	assert.Equal(t, 3, len(deliveries))
+	for _, dl := range deliveries {
+		if dl.Endpoint == downSrv.URL {
+			assert.Equal(t, DeliveryDead, dl.Status)
+			assert.Equal(t, EventDeleted, dl.Event)
+			assert.Equal(t, http.StatusBadGateway, dl.LastStatusCode)
+			assert.Equal(t, "endpoint answered 502 Bad Gateway", dl.LastError)
+		} else {
+			assert.Equal(t, DeliveryDelivered, dl.Status)
+			assert.NotEqual(t, nil, dl.DeliveredAt)
+		}
+	}
+	assert.Equal(t, 2, len(flaky.bodies))
+	assert.Equal(t, true, strings.Contains(strings.Join(flaky.bodies, ""), `"event":"created"`))
+	assert.Equal(t, true, strings.Contains(flaky.bodies[0], `"resource":"invoices"`))
+	assert.Equal(t, true, strings.Contains(flaky.bodies[0], `"number":"INV-1"`))
+
+	app := gin.New()
+	d.RegisterDeliveryLog(app.Group("/deliveries"))
+	w := serveTest(app, http.MethodGet, `/deliveries?filter={"status":"dead"}`, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, true, strings.Contains(w.Body.String(), `"endpoint":"`+downSrv.URL+`"`))
+	assert.Equal(t, http.StatusForbidden, serveTest(app, http.MethodPost, "/deliveries", `{"status":"delivered"}`).Code)
+	dead := strconv.FormatInt(deliveries[2].ID, 10)
+	assert.Equal(t, http.StatusMethodNotAllowed, serveTest(app, http.MethodDelete, "/deliveries/"+dead, "").Code)
+	assert.Equal(t, http.StatusNotFound, serveTest(app, http.MethodPost, "/deliveries/999999/retry", "").Code)
+
+	w = serveTest(app, http.MethodPost, "/deliveries/"+dead+"/retry", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, true, strings.Contains(w.Body.String(), `"status":"pending","attempts":0`))
+	assert.Equal(t, nil, d.Dispatch(ctx))
+	assert.Equal(t, 1, len(down.bodies))
+
+	// deliveries being attempted are neither retried nor claimed again
+	locked := time.Now().Add(time.Hour)
+	assert.Equal(t, nil, testDB.Model(deliveries[2]).Update("locked_until", locked).Error)
+	assert.Equal(t, http.StatusConflict, serveTest(app, http.MethodPost, "/deliveries/"+dead+"/retry", "").Code)
+	assert.Equal(t, nil, testDB.Model(deliveries[2]).Update("locked_until", nil).Error)
+	// a delivery read while due but delivered meanwhile is not claimed
+	stale := *deliveries[2]
+	stale.Status = DeliveryPending
+	ok, err := d.claim(ctx, &stale)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, false, ok)
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.Equal(t, nil, d.Run(cancelled))
+}
+
+func TestDispatcherBackoff(t *testing.T) {
+	d := NewDispatcher(nil)
+	assert.Equal(t, d.Backoff, d.backoff(1))
+	assert.Equal(t, 4*d.Backoff, d.backoff(3))
+	assert.Equal(t, d.MaxBackoff, d.backoff(30))
+}
@@ -14,15 +14,29 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func Serve(app *gin.Engine, addr string) error {
-	return ServeWithTimeout(app, addr, time.Second*3)
+// Worker runs in the background of a server until ctx is cancelled, when the server shuts down.
+type Worker func(ctx context.Context) error
+
+func Serve(app *gin.Engine, addr string, workers ...Worker) error {
+	return ServeWithTimeout(app, addr, time.Second*3, workers...)
 }
 
-func ServeWithTimeout(app *gin.Engine, addr string, timeout time.Duration) error {
+// ServeWithTimeout serves app on addr, along with workers, until SIGINT or SIGTERM. Shutting down
+// cancels the workers and gives them and running requests timeout to finish.
+func ServeWithTimeout(app *gin.Engine, addr string, timeout time.Duration, workers ...Worker) error {
 	srv := &http.Server{
 		Addr:    addr,
 		Handler: app.Handler(),
 	}
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	for _, w := range workers {
+		go func() {
+			if err := w(workerCtx); err != nil && !errors.Is(err, context.Canceled) {
+				log.Printf("worker: %s\n", err)
+			}
+		}()
+	}
 	go func() {
 		// service connections
 		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
@@ -42,11 +56,13 @@ func ServeWithTimeout(app *gin.Engine, addr string, timeout time.Duration) error
 
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
+	stopWorkers()
 	if err := srv.Shutdown(ctx); err != nil {
 		//log.Fatal("Server Shutdown:", err)
 		return fmt.Errorf("server shutdown failed: %w", err)
 	}
 	// catching ctx.Done(). timeout of 5 seconds.
+	// workers get the same grace period as requests
 	select {
 	case <-ctx.Done():
 		log.Println("timeout.")
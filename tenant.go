@@ -0,0 +1,86 @@
+package ginx
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ospiper/ginx/dbx"
+)
+
+var ErrNoTenant = errors.New("tenant not resolved")
+
+// TenantResolver extracts the tenant of a request.
+type TenantResolver func(c *gin.Context) (string, error)
+
+// Tenant resolves the tenant of every request and stores it in the context, where
+// providers of dbx.TenantScoped models pick it up. Unresolved requests are rejected with 400.
+func Tenant(resolver TenantResolver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tenant, err := resolver(c)
+		if err == nil && tenant == "" {
+			err = ErrNoTenant
+		}
+		if err != nil {
+			WriterOf(c, JSONWriter{}).Error(c, WrapError(http.StatusBadRequest, "tenant_required", err))
+			return
+		}
+		c.Set(dbx.TenantContextKey, tenant)
+		c.Next()
+	}
+}
+
+// TenantFromHeader reads the tenant from a request header, e.g. X-Tenant-ID.
+func TenantFromHeader(name string) TenantResolver {
+	return func(c *gin.Context) (string, error) {
+		return c.GetHeader(name), nil
+	}
+}
+
+// TenantFromSubdomain reads the tenant from the host label right below domain,
+// e.g. acme for acme.example.com and api.acme.example.com.
+func TenantFromSubdomain(domain string) TenantResolver {
+	suffix := "." + strings.TrimPrefix(domain, ".")
+	return func(c *gin.Context) (string, error) {
+		host := c.Request.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		sub, ok := strings.CutSuffix(host, suffix)
+		if !ok || sub == "" {
+			return "", fmt.Errorf("%w: %s is not a subdomain of %s", ErrNoTenant, host, domain)
+		}
+		if i := strings.LastIndexByte(sub, '.'); i >= 0 {
+			sub = sub[i+1:]
+		}
+		return sub, nil
+	}
+}
+
+// TenantFromClaim reads the tenant from the claims an authentication middleware stored
+// under key, e.g. jwt.MapClaims. Claims must be a map keyed by string.
+func TenantFromClaim(key, claim string) TenantResolver {
+	return func(c *gin.Context) (string, error) {
+		claims, ok := c.Get(key)
+		if !ok {
+			return "", fmt.Errorf("%w: no claims", ErrNoTenant)
+		}
+		rv := reflect.ValueOf(claims)
+		if rv.Kind() == reflect.Ptr {
+			rv = rv.Elem()
+		}
+		if rv.Kind() != reflect.Map || rv.Type().Key().Kind() != reflect.String {
+			return "", fmt.Errorf("%w: unsupported claims %T", ErrNoTenant, claims)
+		}
+		v := rv.MapIndex(reflect.ValueOf(claim).Convert(rv.Type().Key()))
+		if !v.IsValid() {
+			return "", fmt.Errorf("%w: missing claim %s", ErrNoTenant, claim)
+		}
+		return fmt.Sprint(v.Interface()), nil
+	}
+}
@@ -0,0 +1,132 @@
+package ginx
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/ospiper/ginx/dbx"
+)
+
+var errRollback = errors.New("rollback")
+
+// Transactional wraps every mutating request in a database transaction which is
+// committed when the handler chain succeeds and rolled back when it ends with
+// an error or a status code >= 400. Safe methods are passed through untouched.
+// The response is held back until the transaction is settled, so that a failed
+// commit is reported instead of what the handler wrote.
+// Functions registered with dbx.AfterCommit run once the transaction commits.
+func Transactional(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		hooks := &dbx.CommitHooks{}
+		w := newBufferedWriter(c.Writer)
+		c.Writer = w
+		err := db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+			c.Set(dbx.TxContextKey, tx)
+			c.Set(dbx.CommitHooksContextKey, hooks)
+			defer func() {
+				c.Set(dbx.TxContextKey, nil)
+				c.Set(dbx.CommitHooksContextKey, nil)
+			}()
+			c.Next()
+			if len(c.Errors) > 0 || c.Writer.Status() >= http.StatusBadRequest {
+				return errRollback
+			}
+			return nil
+		})
+		c.Writer = w.ResponseWriter
+		if err != nil && !errors.Is(err, errRollback) {
+			w.discard()
+			_ = c.Error(err)
+			WriterOf(c, JSONWriter{}).Error(c, err)
+			return
+		}
+		w.flush()
+		if err == nil {
+			hooks.Run()
+		}
+	}
+}
+
+// bufferedWriter holds the status and body written by a handler until flush. Headers go to
+// the underlying writer, which sends them along with the status.
+type bufferedWriter struct {
+	gin.ResponseWriter
+	header  http.Header
+	status  int
+	written bool
+	body    bytes.Buffer
+}
+
+func newBufferedWriter(w gin.ResponseWriter) *bufferedWriter {
+	return &bufferedWriter{ResponseWriter: w, header: w.Header().Clone(), status: w.Status()}
+}
+
+func (w *bufferedWriter) WriteHeader(code int) {
+	if code > 0 && !w.written {
+		w.status = code
+	}
+}
+
+func (w *bufferedWriter) WriteHeaderNow() {
+	w.written = true
+}
+
+func (w *bufferedWriter) Write(b []byte) (int, error) {
+	w.written = true
+	return w.body.Write(b)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	w.written = true
+	return w.body.WriteString(s)
+}
+
+func (w *bufferedWriter) Status() int {
+	return w.status
+}
+
+func (w *bufferedWriter) Size() int {
+	if !w.written {
+		return -1
+	}
+	return w.body.Len()
+}
+
+func (w *bufferedWriter) Written() bool {
+	return w.written
+}
+
+// Flush keeps holding the response back.
+func (w *bufferedWriter) Flush() {}
+
+// discard drops the response, restoring the headers set before it was started.
+func (w *bufferedWriter) discard() {
+	h := w.ResponseWriter.Header()
+	clear(h)
+	for k, v := range w.header {
+		h[k] = v
+	}
+	w.status = 0
+	w.written = false
+	w.body.Reset()
+}
+
+// flush writes the held response to the underlying writer.
+func (w *bufferedWriter) flush() {
+	w.ResponseWriter.WriteHeader(w.status)
+	if w.written {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+	if w.body.Len() > 0 {
+		_, _ = w.ResponseWriter.Write(w.body.Bytes())
+	}
+}
@@ -0,0 +1,84 @@
+package ginx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/assert/v2"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/ospiper/ginx/dbx"
+)
+
+func TestTransactional(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open("file::memory:?_foreign_keys=on"))
+	assert.Equal(t, nil, err)
+	sqlDB, err := db.DB()
+	assert.Equal(t, nil, err)
+	// every connection to :memory: opens a database of its own
+	sqlDB.SetMaxOpenConns(1)
+	assert.Equal(t, nil, db.Exec("CREATE TABLE lists (id INTEGER PRIMARY KEY)").Error)
+	assert.Equal(t, nil, db.Exec("INSERT INTO lists (id) VALUES (1)").Error)
+	// the reference is only checked on commit
+	assert.Equal(t, nil, db.Exec(`CREATE TABLE notes (
+		id INTEGER PRIMARY KEY,
+		list_id INTEGER REFERENCES lists (id) DEFERRABLE INITIALLY DEFERRED,
+		body TEXT
+	)`).Error)
+
+	committed := 0
+	app := gin.New()
+	app.Use(Transactional(db))
+	app.POST("/lists/:id/notes", func(c *gin.Context) {
+		ctx := RequestContext(c)
+		err := dbx.Conn(ctx, db).Exec("INSERT INTO notes (list_id, body) VALUES (?, ?)", c.Param("id"), c.Query("body")).Error
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
+		dbx.AfterCommit(ctx, func() { committed++ })
+		if c.Query("body") == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "empty"})
+			return
+		}
+		c.Header("Location", "/notes/1")
+		c.JSON(http.StatusCreated, gin.H{"body": c.Query("body")})
+	})
+	count := func() int64 {
+		var n int64
+		assert.Equal(t, nil, db.Table("notes").Count(&n).Error)
+		return n
+	}
+	serve := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(http.MethodPost, target, nil))
+		return w
+	}
+
+	w := serve("/lists/1/notes?body=a")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, `{"body":"a"}`, w.Body.String())
+	assert.Equal(t, "/notes/1", w.Header().Get("Location"))
+	assert.Equal(t, int64(1), count())
+	assert.Equal(t, 1, committed)
+
+	// a status >= 400 rolls back and is sent as written
+	w = serve("/lists/1/notes")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, `{"error":"empty"}`, w.Body.String())
+	assert.Equal(t, int64(1), count())
+	assert.Equal(t, 1, committed)
+
+	// a failed commit replaces the response of the handler
+	w = serve("/lists/2/notes?body=b")
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, false, strings.Contains(w.Body.String(), `"body"`))
+	assert.Equal(t, "", w.Header().Get("Location"))
+	assert.Equal(t, int64(1), count())
+	assert.Equal(t, 1, committed)
+}
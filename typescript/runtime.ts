@@ -0,0 +1,95 @@
+/** Query parameters of simple-rest list routes, sent JSON encoded. */
+export interface ListParams {
+  filter?: Record<string, unknown>;
+  /** Sort pairs applied in order, e.g. [["title", "ASC"], ["id", "DESC"]]. */
+  sort?: [string, "ASC" | "DESC"][];
+  range?: [number, number];
+  embed?: string[];
+}
+
+/** A page of records and the number of records matching the query. */
+export interface ListResult<T> {
+  records: T[];
+  total?: number;
+}
+
+/** Error thrown for non-2xx responses, read from problem+json or APIResponse bodies. */
+export class APIError extends Error {
+  constructor(
+    readonly status: number,
+    message: string,
+    readonly code?: string,
+    readonly fields?: { field: string; rule: string; param?: string; message: string }[],
+    readonly requestId?: string,
+  ) {
+    super(message);
+    this.name = "APIError";
+  }
+}
+
+export interface ClientOptions {
+  fetch?: typeof fetch;
+  headers?: Record<string, string>;
+}
+
+interface RequestOptions {
+  query?: object;
+  body?: unknown;
+  envelope?: boolean;
+  list?: boolean;
+}
+
+export class BaseClient {
+  constructor(
+    readonly baseURL: string,
+    readonly options: ClientOptions = {},
+  ) {}
+
+  protected async request<T>(method: string, path: string, opts: RequestOptions = {}): Promise<T> {
+    let url = this.baseURL.replace(/\/+$/, "") + path;
+    const query = new URLSearchParams();
+    for (let [k, v] of Object.entries(opts.query ?? {})) {
+      if (v === undefined || v === null) continue;
+      // the server reads the sort pairs as one flat list
+      if (opts.list && k === "sort" && Array.isArray(v)) v = v.flat();
+      query.set(k, typeof v === "object" ? JSON.stringify(v) : String(v));
+    }
+    const qs = query.toString();
+    if (qs) url += "?" + qs;
+    const headers: Record<string, string> = { Accept: "application/json", ...this.options.headers };
+    if (opts.body !== undefined) headers["Content-Type"] = "application/json";
+    const res = await (this.options.fetch ?? fetch)(url, {
+      method,
+      headers,
+      body: opts.body === undefined ? undefined : JSON.stringify(opts.body),
+    });
+    const text = await res.text();
+    let payload: any;
+    try {
+      payload = text ? JSON.parse(text) : undefined;
+    } catch (err) {
+      // error bodies may not be JSON, e.g. the HTML page of a proxy
+      if (res.ok) throw err;
+    }
+    if (!res.ok) {
+      throw new APIError(
+        res.status,
+        payload?.detail ?? payload?.error ?? payload?.title ?? res.statusText,
+        payload?.code,
+        payload?.fields,
+        payload?.request_id,
+      );
+    }
+    if (opts.envelope) {
+      if (opts.list) return { records: payload.data, total: payload.meta?.total } as T;
+      return payload?.data as T;
+    }
+    if (opts.list) return { records: payload, total: contentRangeTotal(res.headers.get("Content-Range")) } as T;
+    return payload as T;
+  }
+}
+
+function contentRangeTotal(header: string | null): number | undefined {
+  const m = header?.match(/\/(\d+)$/);
+  return m ? Number(m[1]) : undefined;
+}
@@ -0,0 +1,330 @@
+// Package typescript generates TypeScript interfaces and a typed fetch client from the
+// OpenAPI documents built by package openapi, e.g.
+//
+//	typescript.Generate(w, openapi.Builder{Title: "api"}.Build(engine.Routes()))
+//
+// or from a served document with cmd/ginx-ts.
+package typescript
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/ospiper/ginx/openapi"
+)
+
+//go:embed runtime.ts
+var runtime string
+
+// reserved are the names declared by the runtime, renamed when a schema uses them.
+var reserved = map[string]bool{
+	"ListParams":    true,
+	"ListResult":    true,
+	"APIError":      true,
+	"ClientOptions": true,
+	"BaseClient":    true,
+	"Client":        true,
+}
+
+// methods orders the operations of a path.
+var methods = []string{"get", "post", "put", "patch", "delete", "head", "options"}
+
+// listParams are the query parameters of simple-rest list routes, typed as ListParams.
+var listParams = []string{"filter", "sort", "range", "embed"}
+
+// Generate writes an interface per schema of doc and a Client class with a method per operation.
+func Generate(w io.Writer, doc *openapi.Document) error {
+	var b bytes.Buffer
+	b.WriteString("// Code generated by ginx typescript. DO NOT EDIT.\n\n")
+	b.WriteString(runtime)
+
+	names := make([]string, 0, len(doc.Components.Schemas))
+	for name := range doc.Components.Schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		s := doc.Components.Schemas[name]
+		b.WriteString("\n")
+		if s != nil && s.Properties != nil {
+			fmt.Fprintf(&b, "export interface %s %s\n", typeName(name), object(s))
+		} else {
+			fmt.Fprintf(&b, "export type %s = %s;\n", typeName(name), typeOf(s))
+		}
+	}
+
+	paths := make([]string, 0, len(doc.Paths))
+	for p := range doc.Paths {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	b.WriteString("\nexport class Client extends BaseClient {\n")
+	first := true
+	for _, p := range paths {
+		item := *doc.Paths[p]
+		for _, method := range methods {
+			if op, ok := item[method]; ok && !streams(op) {
+				if !first {
+					b.WriteString("\n")
+				}
+				first = false
+				writeMethod(&b, method, p, op)
+			}
+		}
+	}
+	b.WriteString("}\n")
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// writeMethod writes the Client method calling op, taking its path parameters, body and query in that order.
+func writeMethod(b *bytes.Buffer, method, p string, op *openapi.Operation) {
+	var args []string
+	path := p
+	var query []*openapi.Parameter
+	for _, param := range op.Parameters {
+		switch param.In {
+		case "path":
+			arg := identifier(param.Name, false)
+			args = append(args, arg+": "+typeOf(param.Schema))
+			path = strings.ReplaceAll(path, "{"+param.Name+"}", "${encodeURIComponent(String("+arg+"))}")
+		case "query":
+			query = append(query, param)
+		}
+	}
+	opts := []string{}
+	if op.RequestBody != nil {
+		if media, ok := op.RequestBody.Content["application/json"]; ok {
+			args = append(args, "body: "+typeOf(media.Schema))
+			opts = append(opts, "body")
+		}
+	}
+	if len(query) > 0 {
+		names := make([]string, len(query))
+		for i, q := range query {
+			names[i] = q.Name
+		}
+		if slices.Equal(names, listParams) {
+			args = append(args, "query?: ListParams")
+		} else {
+			props := make([]string, len(query))
+			required := false
+			for i, q := range query {
+				props[i] = property(q.Name, !q.Required) + ": " + typeOf(q.Schema)
+				required = required || q.Required
+			}
+			arg := "query?: "
+			if required {
+				arg = "query: "
+			}
+			args = append(args, arg+"{ "+strings.Join(props, "; ")+" }")
+		}
+		opts = append(opts, "query")
+	}
+
+	result, envelope, list := resultOf(op)
+	if envelope {
+		opts = append(opts, "envelope: true")
+	}
+	if list {
+		opts = append(opts, "list: true")
+	}
+	if op.Summary != "" {
+		fmt.Fprintf(b, "  /** %s */\n", op.Summary)
+	}
+	fmt.Fprintf(b, "  %s(%s): Promise<%s> {\n", identifier(op.OperationID, false), strings.Join(args, ", "), result)
+	call := fmt.Sprintf("    return this.request(%q, `%s`", strings.ToUpper(method), path)
+	if len(opts) > 0 {
+		call += ", { " + strings.Join(opts, ", ") + " }"
+	}
+	b.WriteString(call + ");\n  }\n")
+}
+
+// streams reports whether op answers something else than JSON, which the client does not call.
+func streams(op *openapi.Operation) bool {
+	for code, res := range op.Responses {
+		if n, err := strconv.Atoi(code); err == nil && n >= 200 && n < 300 && len(res.Content) > 0 {
+			_, ok := res.Content["application/json"]
+			return !ok
+		}
+	}
+	return false
+}
+
+// resultOf returns the type op resolves to, and whether its responses are enveloped or paginated.
+func resultOf(op *openapi.Operation) (string, bool, bool) {
+	codes := make([]int, 0, len(op.Responses))
+	for code := range op.Responses {
+		if n, err := strconv.Atoi(code); err == nil && n >= 200 && n < 300 {
+			codes = append(codes, n)
+		}
+	}
+	if len(codes) == 0 {
+		return "unknown", false, false
+	}
+	slices.Sort(codes)
+	res := op.Responses[strconv.Itoa(codes[0])]
+	_, list := res.Headers["Content-Range"]
+	media, ok := res.Content["application/json"]
+	if codes[0] == http.StatusNoContent || !ok {
+		return "void", false, false
+	}
+	data, envelope := media.Schema, false
+	if data.Properties != nil && data.Properties["success"] != nil && data.Properties["data"] != nil {
+		data, envelope = data.Properties["data"], true
+	}
+	if list && data.Items != nil {
+		return "ListResult<" + typeOf(data.Items) + ">", envelope, true
+	}
+	return typeOf(data), envelope, false
+}
+
+// typeOf returns the TypeScript type of s.
+func typeOf(s *openapi.Schema) string {
+	if s == nil {
+		return "unknown"
+	}
+	if s.Ref != "" {
+		return typeName(strings.TrimPrefix(s.Ref, "#/components/schemas/"))
+	}
+	if len(s.Enum) > 0 {
+		values := make([]string, len(s.Enum))
+		for i, v := range s.Enum {
+			values[i] = literal(v)
+		}
+		return strings.Join(values, " | ")
+	}
+	var types []string
+	switch t := s.Type.(type) {
+	case string:
+		types = []string{t}
+	case []any:
+		for _, v := range t {
+			if name, ok := v.(string); ok {
+				types = append(types, name)
+			}
+		}
+	}
+	if len(types) == 0 {
+		return "unknown"
+	}
+	ts := make([]string, len(types))
+	for i, t := range types {
+		ts[i] = primitive(s, t)
+	}
+	return strings.Join(ts, " | ")
+}
+
+func primitive(s *openapi.Schema, t string) string {
+	switch t {
+	case "string":
+		return "string"
+	case "integer", "number":
+		return "number"
+	case "boolean":
+		return "boolean"
+	case "null":
+		return "null"
+	case "array":
+		item := typeOf(s.Items)
+		if strings.Contains(item, " ") {
+			item = "(" + item + ")"
+		}
+		return item + "[]"
+	case "object":
+		if s.Properties != nil {
+			return object(s)
+		}
+		if s.AdditionalProperties != nil {
+			return "Record<string, " + typeOf(s.AdditionalProperties) + ">"
+		}
+		return "Record<string, unknown>"
+	}
+	return "unknown"
+}
+
+// object returns the body of an interface declaring the properties of s, optional unless required.
+func object(s *openapi.Schema) string {
+	if len(s.Properties) == 0 {
+		return "{}"
+	}
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	b.WriteString("{\n")
+	for _, name := range names {
+		optional := !slices.Contains(s.Required, name)
+		fmt.Fprintf(&b, "  %s: %s;\n", property(name, optional), typeOf(s.Properties[name]))
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+// property returns the key of a property, quoted unless it is an identifier.
+func property(name string, optional bool) string {
+	key := name
+	if identifier(name, true) != name {
+		key = strconv.Quote(name)
+	}
+	if optional {
+		key += "?"
+	}
+	return key
+}
+
+// typeName returns the TypeScript name of the schema name.
+func typeName(name string) string {
+	name = identifier(name, true)
+	if reserved[name] {
+		name += "_"
+	}
+	return name
+}
+
+// identifier turns s into a TypeScript identifier: camelCase unless keep, which only drops invalid runes.
+func identifier(s string, keep bool) string {
+	var b strings.Builder
+	upper := false
+	for _, r := range s {
+		valid := unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '$'
+		switch {
+		case keep && valid:
+			b.WriteRune(r)
+		case keep:
+			b.WriteRune('_')
+		case !valid || r == '_':
+			upper = b.Len() > 0
+		case upper:
+			b.WriteRune(unicode.ToUpper(r))
+			upper = false
+		default:
+			b.WriteRune(r)
+		}
+	}
+	id := b.String()
+	if id == "" || unicode.IsDigit([]rune(id)[0]) {
+		id = "_" + id
+	}
+	return id
+}
+
+func literal(v any) string {
+	switch v := v.(type) {
+	case string:
+		return strconv.Quote(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,84 @@
+package typescript
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/assert/v2"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/ospiper/ginx"
+	"github.com/ospiper/ginx/dbx"
+	"github.com/ospiper/ginx/openapi"
+	"github.com/ospiper/ginx/rest"
+)
+
+type testNote struct {
+	dbx.Model
+	Title string   `json:"title" binding:"required"`
+	Kind  string   `json:"kind,omitempty" binding:"omitempty,oneof=memo todo"`
+	Score *float64 `json:"score"`
+}
+
+func (testNote) NewWithID(id int64) testNote {
+	return testNote{Model: dbx.Model{ID: id}}
+}
+
+type archiveReq struct {
+	Reason string `json:"reason"`
+}
+
+type archiveUri struct {
+	ID int64 `uri:"note_id"`
+}
+
+type Client struct {
+	Name string `json:"name"`
+}
+
+func TestGenerate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open("file::memory:"))
+	assert.Equal(t, nil, err)
+	app := gin.New()
+	(&rest.ResourceController[testNote]{
+		Name:     "notes",
+		Provider: rest.NewProvider[testNote](db),
+		Group:    app.Group("/notes"),
+		Import:   true,
+	}).Register()
+	ginx.HandleAPIWithUriParams(app, http.MethodPost, "/notes/:note_id/archive", func(ctx context.Context, req *archiveReq, uri *archiveUri) (*Client, error) {
+		return nil, nil
+	})
+	ginx.HandleREST(app, http.MethodGet, "/stats", func(ctx context.Context, req *struct {
+		Since string `form:"since" binding:"required"`
+	}) (map[string]int, error) {
+		return nil, nil
+	})
+
+	var b strings.Builder
+	assert.Equal(t, nil, Generate(&b, openapi.Builder{Title: "notes"}.Build(app.Routes())))
+	out := b.String()
+	for _, want := range []string{
+		"export class APIError extends Error",
+		"  sort?: [string, \"ASC\" | \"DESC\"][];",
+		"      if (res.ok) throw err;",
+		"export interface testNote {\n  created_at?: string;\n  deleted_at?: string | null;\n  id?: number;\n  kind?: \"memo\" | \"todo\";\n  score?: number | null;\n  title: string;\n  updated_at?: string;\n}",
+		"export interface Client_ {\n  name?: string;\n}",
+		"  listNotes(query?: ListParams): Promise<ListResult<testNote>> {\n    return this.request(\"GET\", `/notes`, { query, list: true });",
+		"  createNotes(body: testNote): Promise<testNote> {",
+		"  deleteNotes(id: number): Promise<void> {\n    return this.request(\"DELETE\", `/notes/${encodeURIComponent(String(id))}`);",
+		"  postNotesByNoteIdArchive(noteId: number, body: archiveReq): Promise<Client_> {\n    return this.request(\"POST\", `/notes/${encodeURIComponent(String(noteId))}/archive`, { body, envelope: true });",
+		"  importNotes(body: testNote[], query?: { dry_run?: boolean; on_conflict?: \"skip\" | \"update\" | \"fail\"; batch_size?: number }): Promise<ImportReport> {",
+		"  getStats(query: { since: string }): Promise<Record<string, number>> {",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("generated client lacks\n%s\n\ngot:\n%s", want, out)
+		}
+	}
+	assert.Equal(t, false, strings.Contains(out, "exportNotes"))
+}
@@ -21,8 +21,8 @@ func As[T any](v any) (T, bool) {
 	}
 }
 
-func AsIDList[T dbx.WithID](ts []T) []int64 {
-	ret := make([]int64, len(ts))
+func AsIDList[T dbx.WithIDOf[ID], ID comparable](ts []T) []ID {
+	ret := make([]ID, len(ts))
 	for i, t := range ts {
 		ret[i] = t.GetID()
 	}
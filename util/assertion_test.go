@@ -4,6 +4,8 @@ import (
 	"testing"
 
 	"github.com/go-playground/assert/v2"
+
+	"github.com/ospiper/ginx/dbx"
 )
 
 type testElemStruct struct {
@@ -63,3 +65,8 @@ func TestAs(t *testing.T) {
 		})
 	}
 }
+
+func TestAsIDList(t *testing.T) {
+	models := []dbx.Model{{ID: 1}, {ID: 2}}
+	assert.Equal(t, []int64{1, 2}, AsIDList(models))
+}
@@ -0,0 +1,135 @@
+package ginx
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// FieldError describes a field rejected while binding a request.
+type FieldError struct {
+	Field   string `json:"field" xml:"field"`
+	Rule    string `json:"rule" xml:"rule"`
+	Param   string `json:"param,omitempty" xml:"param,omitempty"`
+	Message string `json:"message" xml:"message"`
+}
+
+var (
+	validatorOnce sync.Once
+	messagesMu    sync.RWMutex
+
+	// messages of the built-in rules, %[1]s is the field and %[2]s the rule parameter
+	messages = map[string]string{
+		"required": "%[1]s is required",
+		"email":    "%[1]s must be a valid email address",
+		"url":      "%[1]s must be a valid URL",
+		"uuid":     "%[1]s must be a valid UUID",
+		"oneof":    "%[1]s must be one of [%[2]s]",
+		"min":      "%[1]s must be at least %[2]s",
+		"max":      "%[1]s must be at most %[2]s",
+		"len":      "%[1]s must have length %[2]s",
+		"eq":       "%[1]s must equal %[2]s",
+		"ne":       "%[1]s must not equal %[2]s",
+		"gt":       "%[1]s must be greater than %[2]s",
+		"gte":      "%[1]s must be greater than or equal to %[2]s",
+		"lt":       "%[1]s must be less than %[2]s",
+		"lte":      "%[1]s must be less than or equal to %[2]s",
+		"eqfield":  "%[1]s must equal %[2]s",
+		"nefield":  "%[1]s must not equal %[2]s",
+		"gtfield":  "%[1]s must be greater than %[2]s",
+		"ltfield":  "%[1]s must be less than %[2]s",
+		"type":     "%[1]s must be of type %[2]s",
+	}
+)
+
+// Validator returns the validator used by gin bindings, set up to report fields by
+// their json, form or uri names.
+func Validator() *validator.Validate {
+	v, _ := binding.Validator.Engine().(*validator.Validate)
+	validatorOnce.Do(func() {
+		if v == nil {
+			return
+		}
+		v.RegisterTagNameFunc(func(f reflect.StructField) string {
+			for _, tag := range []string{"json", "form", "uri"} {
+				name, _, _ := strings.Cut(f.Tag.Get(tag), ",")
+				if name == "-" {
+					return ""
+				}
+				if name != "" {
+					return name
+				}
+			}
+			return f.Name
+		})
+	})
+	return v
+}
+
+// RegisterValidation registers a custom field rule usable in binding tags. message is formatted
+// like the built-in ones, with the field name and the rule parameter.
+func RegisterValidation(tag string, fn validator.Func, message string) error {
+	if message != "" {
+		RegisterMessage(tag, message)
+	}
+	return Validator().RegisterValidation(tag, fn)
+}
+
+// RegisterStructValidation registers a struct level validation for types, used for
+// cross-field rules. Report failures with validator.StructLevel.ReportError.
+func RegisterStructValidation(fn validator.StructLevelFunc, types ...any) {
+	Validator().RegisterStructValidation(fn, types...)
+}
+
+// RegisterMessage sets the message of a rule.
+func RegisterMessage(tag, message string) {
+	messagesMu.Lock()
+	defer messagesMu.Unlock()
+	messages[tag] = message
+}
+
+var ErrValidation = errors.New("validation failed")
+
+// FieldErrors translates a binding error into field errors. It returns nil if err does not
+// concern individual fields, e.g. malformed JSON.
+func FieldErrors(err error) []FieldError {
+	var verrs validator.ValidationErrors
+	if errors.As(err, &verrs) {
+		ret := make([]FieldError, len(verrs))
+		for i, fe := range verrs {
+			field := fe.Namespace()
+			// drop the name of the top level struct
+			if _, rest, ok := strings.Cut(field, "."); ok {
+				field = rest
+			}
+			ret[i] = newFieldError(field, fe.Tag(), fe.Param())
+		}
+		return ret
+	}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return []FieldError{newFieldError(typeErr.Field, "type", typeErr.Type.String())}
+	}
+	return nil
+}
+
+func newFieldError(field, rule, param string) FieldError {
+	messagesMu.RLock()
+	format, ok := messages[rule]
+	messagesMu.RUnlock()
+	if !ok {
+		format = "%[1]s failed on the '%[3]s' rule"
+	}
+	return FieldError{
+		Field:   field,
+		Rule:    rule,
+		Param:   param,
+		Message: fmt.Sprintf(format, field, param, rule),
+	}
+}
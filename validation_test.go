@@ -0,0 +1,58 @@
+package ginx
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/assert/v2"
+	"github.com/go-playground/validator/v10"
+)
+
+type testSignup struct {
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required,min=8"`
+	Confirm  string `json:"confirm"`
+}
+
+func TestFieldErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	RegisterStructValidation(func(sl validator.StructLevel) {
+		s := sl.Current().Interface().(testSignup)
+		if s.Password != s.Confirm {
+			sl.ReportError(s.Confirm, "confirm", "Confirm", "eqfield", "password")
+		}
+	}, testSignup{})
+	app := gin.New()
+	app.POST("/signup", RESTHandler(func(ctx context.Context, req *testSignup) (*Empty, error) {
+		return &Empty{}, nil
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/signup", strings.NewReader(`{"email":"nope","password":"secret","confirm":"secret"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+	var resp Problem
+	assert.Equal(t, nil, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, ErrValidation.Error(), resp.Detail)
+	assert.Equal(t, "validation_failed", resp.Code)
+	assert.Equal(t, []FieldError{
+		{Field: "email", Rule: "email", Message: "email must be a valid email address"},
+		{Field: "password", Rule: "min", Param: "8", Message: "password must be at least 8"},
+	}, resp.Fields)
+
+	req = httptest.NewRequest(http.MethodPost, "/signup", strings.NewReader(`{"email":"a@example.com","password":"long enough","confirm":"typo"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	assert.Equal(t, nil, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, []FieldError{
+		{Field: "confirm", Rule: "eqfield", Param: "password", Message: "confirm must equal password"},
+	}, resp.Fields)
+}
@@ -0,0 +1,95 @@
+package ginx
+
+import (
+	"github.com/gin-contrib/requestid"
+	"github.com/gin-gonic/gin"
+)
+
+// ResponseWriter shapes the responses of every ginx handler family.
+// Error implementations must abort the handler chain.
+type ResponseWriter interface {
+	Success(c *gin.Context, status int, data any)
+	Error(c *gin.Context, err error)
+	// List writes a page of records out of total matching ones.
+	List(c *gin.Context, status int, records any, total int64)
+}
+
+const responseWriterKey = "ginx.response_writer"
+
+// UseResponseWriter makes w shape the responses of the engine or route group it is used on.
+func UseResponseWriter(w ResponseWriter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(responseWriterKey, w)
+		c.Next()
+	}
+}
+
+// WriterOf returns the ResponseWriter configured for c, or fallback if there is none.
+func WriterOf(c *gin.Context, fallback ResponseWriter) ResponseWriter {
+	if w, ok := c.Value(responseWriterKey).(ResponseWriter); ok {
+		return w
+	}
+	return fallback
+}
+
+// JSONWriter writes bare bodies and problem+json errors. Like every writer here it answers
+// in the format negotiated with the Accept header, JSON unless asked otherwise.
+// It is the default of RESTHandler and the rest controllers.
+type JSONWriter struct{}
+
+func (JSONWriter) Success(c *gin.Context, status int, data any) {
+	Render(c, status, data)
+}
+
+func (JSONWriter) Error(c *gin.Context, err error) {
+	AbortWithProblem(c, err)
+}
+
+func (JSONWriter) List(c *gin.Context, status int, records any, _ int64) {
+	Render(c, status, records)
+}
+
+// APIErrorWriter is JSONWriter reporting errors as APIError.
+type APIErrorWriter struct {
+	JSONWriter
+}
+
+func (APIErrorWriter) Error(c *gin.Context, err error) {
+	e := MapError(err)
+	AbortWithRender(c, e.Status, &APIError{
+		Error:     e.Error(),
+		Code:      e.Code,
+		Fields:    e.Fields,
+		RequestID: requestid.Get(c),
+	})
+}
+
+// EnvelopeWriter wraps every response in an APIResponse. It is the default of APIHandler.
+type EnvelopeWriter struct{}
+
+func (EnvelopeWriter) Success(c *gin.Context, status int, data any) {
+	Render(c, status, &APIResponse{
+		Success:   true,
+		Data:      data,
+		RequestID: requestid.Get(c),
+	})
+}
+
+func (EnvelopeWriter) Error(c *gin.Context, err error) {
+	e := MapError(err)
+	AbortWithRender(c, e.Status, &APIResponse{
+		Error:     e.Error(),
+		Code:      e.Code,
+		Fields:    e.Fields,
+		RequestID: requestid.Get(c),
+	})
+}
+
+func (EnvelopeWriter) List(c *gin.Context, status int, records any, total int64) {
+	Render(c, status, &APIResponse{
+		Success:   true,
+		Data:      records,
+		Meta:      &ListMeta{Total: total},
+		RequestID: requestid.Get(c),
+	})
+}